@@ -9,20 +9,24 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/auth"
+	"github.com/HalvaPovidlo/halva-services/pkg/jwt"
 )
 
 type Config struct {
 	General GeneralConfig
 	Login   auth.Config
+	JWT     jwt.Config
 }
 
 type GeneralConfig struct {
 	Debug  bool
-	Host   string `yaml:"host" split_words:"true"`
-	Port   string `yaml:"port" split_words:"true"`
-	Web    string `yaml:"web" split_words:"true"`
-	Secret string `yaml:"secret" split_words:"true"`
-	Level  zapcore.Level
+	Host   string   `yaml:"host" split_words:"true"`
+	Port   string   `yaml:"port" split_words:"true"`
+	Web    string   `yaml:"web" split_words:"true"`
+	Admins []string `yaml:"admins"`
+	// RefreshCookie delivers refresh tokens as HttpOnly cookies instead of the response body
+	RefreshCookie bool `yaml:"refresh_cookie" split_words:"true"`
+	Level         zapcore.Level
 }
 
 func InitConfig(configPathEnv, envPrefix string) (Config, error) {
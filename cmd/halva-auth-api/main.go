@@ -5,14 +5,17 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/patrickmn/go-cache"
 	"go.uber.org/zap"
 
 	"github.com/HalvaPovidlo/halva-services/cmd/halva-auth-api/config"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/allowlist"
 	apiv1 "github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/api/v1"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/auth"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/user"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 	"github.com/HalvaPovidlo/halva-services/pkg/echos"
 	"github.com/HalvaPovidlo/halva-services/pkg/firestore"
@@ -20,7 +23,10 @@ import (
 	"github.com/HalvaPovidlo/halva-services/pkg/log"
 )
 
-const configPathEnv = "CONFIG_PATH"
+const (
+	configPathEnv        = "CONFIG_PATH"
+	refreshPruneInterval = time.Hour
+)
 
 func main() {
 	cfg, err := config.InitConfig(configPathEnv, "")
@@ -35,17 +41,32 @@ func main() {
 		logger.Fatal("failed to init firestore client", zap.Error(err))
 	}
 
-	userService := user.New(user.NewCache(cache.NoExpiration, cache.NoExpiration), user.NewStorage(fireClient))
+	userService := user.New(user.NewCache(cache.NoExpiration, cache.NoExpiration), user.NewStorage(fireClient), cfg.General.Admins)
 	err = userService.FillCache(ctx)
 	if err != nil {
 		logger.Fatal("failed to fill user service cache", zap.Error(err))
 	}
 
-	jwtService := jwt.New(cfg.General.Secret)
-	authService := auth.New(cfg.Login)
-	handler := apiv1.New(cfg.General.Host, cfg.General.Port, cfg.General.Web, authService, userService, jwtService)
+	jwtService, err := jwt.New(cfg.JWT)
+	if err != nil {
+		logger.Fatal("failed to init jwt service", zap.Error(err))
+	}
 
-	echoServer := echos.New()
+	allowList := allowlist.New(allowlist.NewCache(cache.NoExpiration, cache.NoExpiration), allowlist.NewStorage(fireClient))
+	if err := allowList.FillCache(ctx, cfg.Login.KnownUsers); err != nil {
+		logger.Fatal("failed to fill allow-list cache", zap.Error(err))
+	}
+
+	authService := auth.New(cfg.Login, allowList, auth.NewStorage(fireClient))
+	authService.StartPruning(ctx, refreshPruneInterval)
+	handler := apiv1.New(cfg.General.Host, cfg.General.Port, cfg.General.Web, cfg.General.RefreshCookie, authService, userService, allowList, apikey.New(apikey.NewStorage(fireClient)), jwtService)
+
+	var credentialOrigins []string
+	if cfg.General.RefreshCookie {
+		credentialOrigins = append(credentialOrigins, cfg.General.Host+":"+cfg.General.Web)
+		credentialOrigins = append(credentialOrigins, cfg.Login.ReturnOrigins...)
+	}
+	echoServer := echos.New(credentialOrigins...)
 	echoServer.RegisterHandlers(handler)
 	echoServer.Run(cfg.General.Port, logger)
 
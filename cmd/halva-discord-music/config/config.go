@@ -9,18 +9,19 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/discord"
+	"github.com/HalvaPovidlo/halva-services/pkg/jwt"
 )
 
 type Config struct {
 	General GeneralConfig
 	Discord discord.Config
+	JWT     jwt.Config
 }
 
 type GeneralConfig struct {
 	Debug      bool
 	Port       string `yaml:"port" split_words:"true"`
-	Secret     string
-	StateTicks int `yaml:"state_ticks" split_words:"true"`
+	StateTicks int    `yaml:"state_ticks" split_words:"true"`
 	Level      zapcore.Level
 }
 
@@ -19,6 +19,7 @@ import (
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/music/player"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/music/player/playlist"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/music/search"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 	"github.com/HalvaPovidlo/halva-services/pkg/echos"
 	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
@@ -63,7 +64,13 @@ func main() {
 	discordHandler := apiv1.NewDiscord(discordClient, musicPlayer, searcher)
 	discordHandler.RegisterRoutes()
 
-	handler := apiv1.New(ctx, discordClient, searcher, musicPlayer, socket.NewManager(ctx), jwt.New(cfg.General.Secret))
+	jwtService, err := jwt.New(cfg.JWT)
+	if err != nil {
+		logger.Fatal("failed to init jwt service", zap.Error(err))
+	}
+	jwtService.UseAPIKeys(apikey.New(apikey.NewStorage(fireClient)))
+
+	handler := apiv1.New(ctx, discordClient, searcher, musicPlayer, socket.NewManager(ctx), jwtService)
 
 	echoServer := echos.New()
 	echoServer.RegisterHandlers(handler)
@@ -3,23 +3,40 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v2"
+
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/kinopoisk"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/refresher"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+	"github.com/HalvaPovidlo/halva-services/pkg/jwt"
+)
+
+const (
+	defaultRefreshMaxAge = 7 * 24 * time.Hour
+	defaultRefreshRate   = time.Second
 )
 
 type Config struct {
 	General GeneralConfig
+	JWT     jwt.Config
 }
 
 type GeneralConfig struct {
-	Debug     bool
-	Kinopoisk string
-	Port      string `yaml:"port" split_words:"true"`
-	Secret    string `yaml:"secret" split_words:"true"`
-	Sort      string
-	Level     zapcore.Level
+	Debug           bool
+	Kinopoisk       string
+	KinopoiskClient kinopoisk.Config `yaml:"kinopoisk_client"`
+	Imdb            string           // OMDb API key, IMDb links are not supported without it
+	Tmdb            string
+	ProviderTimeout time.Duration `yaml:"provider_timeout" split_words:"true"` // timeout of IMDb and TMDB requests
+	Port            string        `yaml:"port" split_words:"true"`
+	Sort            string
+	Rating          film.RatingConfig
+	Refresh         refresher.Config
+	Level           zapcore.Level
 }
 
 func InitConfig(configPathEnv, envPrefix string) (Config, error) {
@@ -46,5 +63,16 @@ func InitConfig(configPathEnv, envPrefix string) (Config, error) {
 	if err := envconfig.Process(envPrefix, &cfg); err != nil {
 		return Config{}, err
 	}
+
+	if cfg.General.Sort == "" {
+		cfg.General.Sort = "halva"
+	}
+	if cfg.General.Refresh.MaxAge == 0 {
+		cfg.General.Refresh.MaxAge = defaultRefreshMaxAge
+	}
+	if cfg.General.Refresh.Rate == 0 {
+		cfg.General.Refresh.Rate = defaultRefreshRate
+	}
+
 	return cfg, nil
 }
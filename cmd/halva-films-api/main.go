@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/patrickmn/go-cache"
 	"go.uber.org/zap"
@@ -12,7 +13,18 @@ import (
 	"github.com/HalvaPovidlo/halva-services/cmd/halva-films-api/config"
 	apiv1 "github.com/HalvaPovidlo/halva-services/internal/halva-films-api/api/v1"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/film"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/imdb"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/kinopoisk"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/planner"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/provider"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/recommender"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/refresher"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/session"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/stats"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/tmdb"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/user"
+	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 	"github.com/HalvaPovidlo/halva-services/pkg/echos"
 	"github.com/HalvaPovidlo/halva-services/pkg/firestore"
@@ -20,7 +32,10 @@ import (
 	"github.com/HalvaPovidlo/halva-services/pkg/log"
 )
 
-const configPathEnv = "CONFIG_PATH"
+const (
+	configPathEnv   = "CONFIG_PATH"
+	usersExpiration = 10 * time.Minute
+)
 
 func main() {
 	cfg, err := config.InitConfig(configPathEnv, "")
@@ -30,13 +45,29 @@ func main() {
 	logger := log.NewLogger(cfg.General.Debug)
 	ctx := contexts.WithLogger(context.Background(), logger)
 
+	ratings, err := pfilm.NewRatings(cfg.General.Rating)
+	if err != nil {
+		logger.Fatal("bad rating config", zap.Error(err))
+	}
+	defaultRating, _ := ratings.Get("")
+	if _, err := pfilm.ParseSort(cfg.General.Sort, defaultRating); err != nil {
+		logger.Fatal("bad default sort", zap.Error(err))
+	}
+
 	fireClient, err := firestore.New(ctx, "halvabot-firebase.json")
 	if err != nil {
 		logger.Fatal("failed to init firestore client", zap.Error(err))
 	}
 
+	providers := []provider.Provider{kinopoisk.New(cfg.General.Kinopoisk, cfg.General.KinopoiskClient)}
+	if cfg.General.Imdb != "" {
+		providers = append(providers, imdb.New(cfg.General.Imdb, cfg.General.ProviderTimeout))
+	}
+	if cfg.General.Tmdb != "" {
+		providers = append(providers, tmdb.New(cfg.General.Tmdb, cfg.General.ProviderTimeout))
+	}
 	filmService := film.New(
-		kinopoisk.New(cfg.General.Kinopoisk),
+		provider.New(providers...),
 		film.NewCache(cache.NoExpiration, cache.NoExpiration),
 		film.NewStorage(fireClient),
 	)
@@ -45,8 +76,22 @@ func main() {
 		logger.Fatal("failed to fill film service cache", zap.Error(err))
 	}
 
-	jwtService := jwt.New(cfg.General.Secret)
-	handler := apiv1.New(filmService, jwtService, cfg.General.Sort)
+	refresher.New(filmService, cfg.General.Refresh).Start(ctx)
+
+	sessionService := session.New(filmService, session.NewCache(cache.NoExpiration, cache.NoExpiration), session.NewStorage(fireClient))
+	if err = sessionService.FillCache(ctx); err != nil {
+		logger.Fatal("failed to fill session service cache", zap.Error(err))
+	}
+
+	// user names are shown in recommendations, new users are picked up when the cache expires
+	userService := user.New(user.NewStorage(fireClient), usersExpiration)
+
+	jwtService, err := jwt.New(cfg.JWT)
+	if err != nil {
+		logger.Fatal("failed to init jwt service", zap.Error(err))
+	}
+	jwtService.UseAPIKeys(apikey.New(apikey.NewStorage(fireClient)))
+	handler := apiv1.New(filmService, planner.New(filmService), sessionService, ratings, stats.New(filmService), recommender.New(filmService, userService), jwtService, cfg.General.Sort)
 
 	echoServer := echos.New()
 	echoServer.RegisterHandlers(handler)
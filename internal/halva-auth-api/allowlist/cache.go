@@ -0,0 +1,45 @@
+package allowlist
+
+import (
+	"time"
+
+	pcache "github.com/patrickmn/go-cache"
+)
+
+type cache struct {
+	*pcache.Cache
+}
+
+func NewCache(defaultExpiration, cleanupInterval time.Duration) *cache {
+	return &cache{
+		Cache: pcache.New(defaultExpiration, cleanupInterval),
+	}
+}
+
+func (c *cache) Set(entry *Entry) {
+	if entry != nil {
+		c.SetDefault(entry.ID, *entry)
+	}
+}
+
+func (c *cache) Get(id string) (*Entry, bool) {
+	v, ok := c.Cache.Get(id)
+	if !ok {
+		return nil, false
+	}
+	if e, ok := v.(Entry); ok {
+		return &e, true
+	}
+	return nil, false
+}
+
+func (c *cache) All() []Entry {
+	items := c.Items()
+	result := make([]Entry, 0, len(items))
+	for _, v := range items {
+		if e, ok := v.Object.(Entry); ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package allowlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+type Entry struct {
+	ID        string    `firestore:"-"`
+	AddedBy   string    `firestore:"added_by,omitempty"`
+	Invite    bool      `firestore:"invite"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// Invite is stored by the hash of its code, the code itself is shown only once to the admin
+type Invite struct {
+	ID        string    `firestore:"-"`
+	CreatedBy string    `firestore:"created_by"`
+	CreatedAt time.Time `firestore:"created_at"`
+	ExpiresAt time.Time `firestore:"expires_at"`
+	UsedBy    string    `firestore:"used_by,omitempty"`
+	UsedAt    time.Time `firestore:"used_at,omitempty"`
+}
+
+func (i *Invite) Valid(now time.Time) bool {
+	return i.UsedBy == "" && i.ExpiresAt.After(now)
+}
+
+func Parse(doc *firestore.DocumentSnapshot) (*Entry, error) {
+	var e Entry
+	if err := doc.DataTo(&e); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	e.ID = doc.Ref.ID
+	return &e, nil
+}
+
+func ParseInvite(doc *firestore.DocumentSnapshot) (*Invite, error) {
+	var i Invite
+	if err := doc.DataTo(&i); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	i.ID = doc.Ref.ID
+	return &i, nil
+}
+
+func hashCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,136 @@
+package allowlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const inviteCodeSize = 18
+
+var ErrBadInvite = errors.New("invite is invalid or expired")
+
+type cacheService interface {
+	Set(entry *Entry)
+	Get(id string) (*Entry, bool)
+	Delete(id string)
+	All() []Entry
+}
+
+type storageService interface {
+	All(ctx context.Context) ([]Entry, error)
+	Set(ctx context.Context, entry *Entry) error
+	Delete(ctx context.Context, id string) error
+	SetInvite(ctx context.Context, invite *Invite) error
+	Redeem(ctx context.Context, inviteID string, entry *Entry) error
+}
+
+type service struct {
+	cache   cacheService
+	storage storageService
+}
+
+func New(cache cacheService, storage storageService) *service {
+	return &service{
+		cache:   cache,
+		storage: storage,
+	}
+}
+
+// FillCache loads the allow-list, the seed is used only to bootstrap an empty one
+func (s *service) FillCache(ctx context.Context, seed []string) error {
+	entries, err := s.storage.All(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get allowed users from storage")
+	}
+	for i := range entries {
+		s.cache.Set(&entries[i])
+	}
+	if len(entries) != 0 {
+		return nil
+	}
+
+	for i := range seed {
+		if _, err := s.Add(ctx, seed[i], ""); err != nil {
+			return errors.Wrap(err, "add seed user")
+		}
+	}
+	return nil
+}
+
+func (s *service) Allowed(id string) bool {
+	_, ok := s.cache.Get(id)
+	return ok
+}
+
+func (s *service) All() []Entry {
+	entries := s.cache.All()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+func (s *service) Add(ctx context.Context, id, addedBy string) (*Entry, error) {
+	if e, ok := s.cache.Get(id); ok {
+		return e, nil
+	}
+
+	e := &Entry{
+		ID:        id,
+		AddedBy:   addedBy,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.Set(ctx, e); err != nil {
+		return nil, errors.Wrap(err, "add allowed user to storage")
+	}
+	s.cache.Set(e)
+	return e, nil
+}
+
+func (s *service) Remove(ctx context.Context, id string) error {
+	if err := s.storage.Delete(ctx, id); err != nil {
+		return errors.Wrap(err, "delete allowed user from storage")
+	}
+	s.cache.Delete(id)
+	return nil
+}
+
+// Invite creates a single-use invite code, the code is not stored and can't be restored later
+func (s *service) Invite(ctx context.Context, createdBy string, ttl time.Duration) (string, *Invite, error) {
+	raw := make([]byte, inviteCodeSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, errors.Wrap(err, "generate invite code")
+	}
+	code := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	invite := &Invite{
+		ID:        hashCode(code),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.storage.SetInvite(ctx, invite); err != nil {
+		return "", nil, errors.Wrap(err, "save invite to storage")
+	}
+	return code, invite, nil
+}
+
+// Redeem adds the user to the allow-list if the invite code is valid
+func (s *service) Redeem(ctx context.Context, code, userID string) error {
+	e := &Entry{
+		ID:        userID,
+		Invite:    true,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.Redeem(ctx, hashCode(code), e); err != nil {
+		return err
+	}
+	s.cache.Set(e)
+	return nil
+}
@@ -0,0 +1,98 @@
+package allowlist
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
+)
+
+const approximateUsersNumber = 10
+
+type storage struct {
+	*firestore.Client
+}
+
+func NewStorage(client *firestore.Client) *storage {
+	return &storage{
+		Client: client,
+	}
+}
+
+func (s *storage) All(ctx context.Context) ([]Entry, error) {
+	entries := make([]Entry, 0, approximateUsersNumber)
+	iter := s.Collection(fire.AllowedCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "get next iterator")
+		}
+		e, err := Parse(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse allowed user doc")
+		}
+		entries = append(entries, *e)
+	}
+	return entries, nil
+}
+
+func (s *storage) Set(ctx context.Context, entry *Entry) error {
+	_, err := s.Collection(fire.AllowedCollection).Doc(entry.ID).Set(ctx, entry)
+	return errors.Wrap(err, "set allowed user doc")
+}
+
+func (s *storage) Delete(ctx context.Context, id string) error {
+	_, err := s.Collection(fire.AllowedCollection).Doc(id).Delete(ctx)
+	return errors.Wrap(err, "delete allowed user doc")
+}
+
+func (s *storage) SetInvite(ctx context.Context, invite *Invite) error {
+	_, err := s.Collection(fire.InvitesCollection).Doc(invite.ID).Set(ctx, invite)
+	return errors.Wrap(err, "set invite doc")
+}
+
+// Redeem marks the invite as used by the user and adds the user to the allow-list in a single transaction
+func (s *storage) Redeem(ctx context.Context, inviteID string, entry *Entry) error {
+	var (
+		inviteRef = s.Collection(fire.InvitesCollection).Doc(inviteID)
+		entryRef  = s.Collection(fire.AllowedCollection).Doc(entry.ID)
+	)
+	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(inviteRef)
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return ErrBadInvite
+		case err != nil:
+			return errors.Wrap(err, "get invite doc")
+		}
+
+		invite, err := ParseInvite(doc)
+		if err != nil {
+			return errors.Wrap(err, "parse invite doc")
+		}
+		if !invite.Valid(entry.CreatedAt) {
+			return ErrBadInvite
+		}
+
+		invite.UsedBy = entry.ID
+		invite.UsedAt = entry.CreatedAt
+		entry.AddedBy = invite.CreatedBy
+		if err := tx.Set(inviteRef, invite); err != nil {
+			return errors.Wrap(err, "tx set invite doc")
+		}
+		return errors.Wrap(tx.Set(entryRef, entry), "tx set allowed user doc")
+	})
+	if errors.Is(err, ErrBadInvite) {
+		return err
+	}
+	return errors.Wrap(err, "run redeem invite transaction")
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
+	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/allowlist"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/auth"
+	puser "github.com/HalvaPovidlo/halva-services/internal/halva-auth-api/user"
 	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 	"github.com/HalvaPovidlo/halva-services/pkg/jwt"
 )
@@ -19,60 +24,117 @@ import (
 const (
 	callbackPath     = "/auth/callback"
 	discordAvatarURL = "https://cdn.discordapp.com/avatars/"
+	jwksCacheControl = "public, max-age=300"
+	defaultInviteTTL = 24 * time.Hour
+	maxInviteTTL     = 30 * 24 * time.Hour
+	refreshCookie    = "halva_refresh"
+	refreshPath      = "/api/v1"
 )
 
 type jwtService interface {
-	Generate(userID string) (string, error)
+	Generate(userID, role string) (string, error)
 	Authorization(next echo.HandlerFunc) echo.HandlerFunc
+	RequireRole(roles ...string) echo.MiddlewareFunc
 	ExtractUserID(c echo.Context) (string, error)
+	ExtractRole(c echo.Context) (string, error)
+	JWKS() jwt.JWKSet
 }
 
 type loginService interface {
-	RedirectURL(redirectURL, key string) string
-	GetDiscordInfo(ctx context.Context, authCode, reqState, key string) (string, string, string, error)
-	GenerateRefreshToken(userID string) string
-	ValidateRefreshToken(userID, token string) (string, error)
-	ExpireRefreshToken(token string)
-	ExpireAllRefreshTokens(userID string)
+	LoginURL(redirectURL, returnTo, invite string) (string, error)
+	ValidReturnTo(returnTo string) bool
+	GetDiscordInfo(ctx context.Context, authCode, reqState string) (*auth.DiscordUser, string, error)
+	GenerateRefreshToken(ctx context.Context, userID string, device auth.Device) (string, error)
+	ValidateRefreshToken(ctx context.Context, userID, token string, device auth.Device) (string, error)
+	ExpireRefreshToken(ctx context.Context, token string) error
+	ExpireAllRefreshTokens(ctx context.Context, userID string) error
+	Sessions(ctx context.Context, userID string) ([]auth.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	LoginCode(userID string) (string, error)
+	ExchangeLoginCode(code string) (string, error)
+	RefreshTTL() time.Duration
 }
 
 type userService interface {
-	Upsert(ctx context.Context, id, username, avatar string) error
+	Upsert(ctx context.Context, id, username, avatar, role string) (*user.Item, error)
+	SetRole(ctx context.Context, id, role string) (*user.Item, error)
 	Get(ctx context.Context, id string) (*user.Item, error)
 	All(ctx context.Context) (user.Items, error)
 }
 
+type allowListService interface {
+	All() []allowlist.Entry
+	Add(ctx context.Context, id, addedBy string) (*allowlist.Entry, error)
+	Remove(ctx context.Context, id string) error
+	Invite(ctx context.Context, createdBy string, ttl time.Duration) (string, *allowlist.Invite, error)
+}
+
+type apiKeyService interface {
+	Issue(ctx context.Context, ownerID, role, name string, scopes []string) (string, *apikey.Key, error)
+	Keys(ctx context.Context, ownerID string) ([]apikey.Key, error)
+	Revoke(ctx context.Context, id, ownerID string) error
+	RevokeAll(ctx context.Context, ownerID string) error
+}
+
 type handler struct {
-	host string
-	port string
-	web  string
-	auth loginService
-	jwt  jwtService
-	user userService
+	host    string
+	port    string
+	web     string
+	cookie  bool
+	auth    loginService
+	jwt     jwtService
+	user    userService
+	allowed allowListService
+	keys    apiKeyService
 }
 
-func New(host, port, web string, login loginService, user userService, jwtService jwtService) *handler {
+// New creates the auth handler, with cookie set refresh tokens are delivered as HttpOnly cookies
+func New(host, port, web string, cookie bool, login loginService, user userService, allowed allowListService, keys apiKeyService, jwtService jwtService) *handler {
 	return &handler{
-		host: host,
-		port: port,
-		web:  web,
-		auth: login,
-		user: user,
-		jwt:  jwtService,
+		host:    host,
+		port:    port,
+		web:     web,
+		cookie:  cookie,
+		auth:    login,
+		user:    user,
+		allowed: allowed,
+		keys:    keys,
+		jwt:     jwtService,
 	}
 }
 
 func (h *handler) RegisterRoutes(e *echo.Echo) {
 	e.GET("/api/v1/login", h.login)
+	e.POST("/api/v1/token", h.token)
 	e.POST("/api/v1/refresh", h.refresh)
 	e.POST("/api/v1/logout", h.logout, h.jwt.Authorization)
 	e.GET("/api/v1/users", h.users, h.jwt.Authorization)
+	e.PATCH("/api/v1/users/:id/role", h.setRole, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin))
+	e.GET("/api/v1/allowlist", h.allowList, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin))
+	e.POST("/api/v1/allowlist/:id", h.allow, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin))
+	e.DELETE("/api/v1/allowlist/:id", h.disallow, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin))
+	e.POST("/api/v1/invites", h.invite, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin))
+	e.GET("/api/v1/sessions", h.sessions, h.jwt.Authorization)
+	e.DELETE("/api/v1/sessions/:id", h.revokeSession, h.jwt.Authorization)
+	e.GET("/api/v1/keys", h.apiKeys, h.jwt.Authorization)
+	e.POST("/api/v1/keys", h.issueAPIKey, h.jwt.Authorization, h.jwt.RequireRole(user.RoleAdmin, user.RoleMember))
+	e.DELETE("/api/v1/keys/:id", h.revokeAPIKey, h.jwt.Authorization)
 	e.GET(callbackPath, h.callback)
+	e.GET("/.well-known/jwks.json", h.jwks)
 }
 
 func (h *handler) login(c echo.Context) error {
+	returnTo := c.QueryParam("return_to")
+	if !h.auth.ValidReturnTo(returnTo) {
+		return c.String(http.StatusBadRequest, "return_to is not allowed")
+	}
+
 	path := h.host + ":" + h.port + callbackPath
-	return c.Redirect(http.StatusTemporaryRedirect, h.auth.RedirectURL(path, c.RealIP()))
+	redirect, err := h.auth.LoginURL(path, returnTo, c.QueryParam("invite"))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.Redirect(http.StatusTemporaryRedirect, redirect)
 }
 
 func (h *handler) logout(c echo.Context) error {
@@ -81,18 +143,24 @@ func (h *handler) logout(c echo.Context) error {
 		return c.String(http.StatusUnauthorized, err.Error())
 	}
 
-	refresh := c.QueryParam("refresh")
+	refresh := h.refreshToken(c)
 	if refresh == "" {
 		return c.String(http.StatusBadRequest, "Refresh token is empty")
 	}
 
-	all := c.QueryParam("all")
-	if all == "true" {
-		h.auth.ExpireAllRefreshTokens(userID)
+	ctx := c.Request().Context()
+	if c.QueryParam("all") == "true" {
+		err = h.auth.ExpireAllRefreshTokens(ctx, userID)
 	} else {
-		h.auth.ExpireRefreshToken(refresh)
+		err = h.auth.ExpireRefreshToken(ctx, refresh)
+	}
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
 	}
 
+	if h.cookie {
+		h.setRefreshCookie(c, "", -1)
+	}
 	return c.String(http.StatusOK, "You were successfully logged out")
 }
 
@@ -102,25 +170,54 @@ func (h *handler) refresh(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "UserID is empty")
 	}
 
-	refresh := c.QueryParam("refresh")
+	refresh := h.refreshToken(c)
 	if refresh == "" {
 		return c.String(http.StatusBadRequest, "Refresh token is empty")
 	}
 
-	newToken, err := h.auth.ValidateRefreshToken(userID, refresh)
+	ctx := c.Request().Context()
+	newToken, err := h.auth.ValidateRefreshToken(ctx, userID, refresh, device(c))
 	switch {
 	case errors.Is(err, auth.ErrInvalidToken):
 		return c.String(http.StatusUnprocessableEntity, "Invalid refresh token")
+	case errors.Is(err, auth.ErrTokenReused):
+		return c.String(http.StatusUnauthorized, "Refresh token was already used, the login was revoked")
 	case err != nil:
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
 
+	return h.respondTokens(c, userID, newToken)
+}
+
+// token exchanges the one-time code from the login redirect for the access and refresh tokens
+func (h *handler) token(c echo.Context) error {
+	code := c.FormValue("code")
+	if code == "" {
+		return c.String(http.StatusBadRequest, "Code is empty")
+	}
+
+	userID, err := h.auth.ExchangeLoginCode(code)
+	switch {
+	case errors.Is(err, auth.ErrBadCode):
+		return c.String(http.StatusUnprocessableEntity, "Code is invalid or expired, login again")
+	case err != nil:
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	refreshToken, err := h.auth.GenerateRefreshToken(c.Request().Context(), userID, device(c))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return h.respondTokens(c, userID, refreshToken)
+}
+
+func (h *handler) respondTokens(c echo.Context, userID, refreshToken string) error {
 	u, err := h.user.Get(c.Request().Context(), userID)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
 
-	accessToken, err := h.jwt.Generate(userID)
+	accessToken, err := h.jwt.Generate(userID, u.Role)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
@@ -128,14 +225,43 @@ func (h *handler) refresh(c echo.Context) error {
 	resp := loginResponse{
 		Token:        accessToken,
 		ID:           userID,
+		Role:         u.Role,
 		Username:     u.Username,
 		Avatar:       u.Avatar,
-		RefreshToken: newToken,
+		RefreshToken: refreshToken,
 		Expiration:   time.Now().Add(jwt.TokenTTL),
 	}
+	if h.cookie {
+		h.setRefreshCookie(c, refreshToken, int(h.auth.RefreshTTL().Seconds()))
+		resp.RefreshToken = ""
+	}
 	return c.JSON(http.StatusOK, resp)
 }
 
+// refreshToken takes the token from the query and falls back to the cookie
+func (h *handler) refreshToken(c echo.Context) string {
+	if refresh := c.QueryParam("refresh"); refresh != "" || !h.cookie {
+		return refresh
+	}
+	cookie, err := c.Cookie(refreshCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (h *handler) setRefreshCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     refreshCookie,
+		Value:    value,
+		Path:     refreshPath,
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(h.host, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
 func (h *handler) users(c echo.Context) error {
 	var users usersResponse
 	all, err := h.user.All(c.Request().Context())
@@ -149,64 +275,405 @@ func (h *handler) users(c echo.Context) error {
 			ID:       u.ID,
 			Username: u.Username,
 			Avatar:   u.Avatar,
+			Role:     u.Role,
 		})
 	}
 
 	return c.JSON(http.StatusOK, users)
 }
 
+func (h *handler) setRole(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "UserID is empty")
+	}
+
+	role := c.QueryParam("role")
+	if !user.ValidRole(role) {
+		return c.String(http.StatusBadRequest, "role should be in (admin, member, guest)")
+	}
+
+	ctx := c.Request().Context()
+	prev, err := h.user.Get(ctx, id)
+	switch {
+	case errors.Is(err, puser.ErrNotFound):
+		return c.String(http.StatusNotFound, "User not found")
+	case err != nil:
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	prevRole := prev.Role
+
+	u, err := h.user.SetRole(ctx, id, role)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	// keys were issued with the old role, a demoted user must issue them again
+	if user.RoleAbove(prevRole, role) {
+		if err := h.keys.RevokeAll(ctx, id); err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, userResponse{
+		ID:       u.ID,
+		Username: u.Username,
+		Avatar:   u.Avatar,
+		Role:     u.Role,
+	})
+}
+
+func (h *handler) allowList(c echo.Context) error {
+	all := h.allowed.All()
+	resp := allowListResponse{Users: make([]allowedResponse, 0, len(all))}
+	for i := range all {
+		resp.Users = append(resp.Users, buildAllowed(&all[i]))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) allow(c echo.Context) error {
+	adminID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "UserID is empty")
+	}
+
+	entry, err := h.allowed.Add(c.Request().Context(), id, adminID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, buildAllowed(entry))
+}
+
+func (h *handler) disallow(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "UserID is empty")
+	}
+
+	ctx := c.Request().Context()
+	if err := h.allowed.Remove(ctx, id); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if err := h.auth.ExpireAllRefreshTokens(ctx, id); err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return c.String(http.StatusOK, "User was removed from the allow-list")
+}
+
+func (h *handler) invite(c echo.Context) error {
+	adminID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	ttl := defaultInviteTTL
+	if ttlStr := c.QueryParam("ttl"); ttlStr != "" {
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil || ttl <= 0 || ttl > maxInviteTTL {
+			return c.String(http.StatusBadRequest, "ttl should be a positive duration not longer than 720h")
+		}
+	}
+
+	code, invite, err := h.allowed.Invite(c.Request().Context(), adminID, ttl)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	link := h.host + ":" + h.port + "/api/v1/login?" + url.Values{"invite": []string{code}}.Encode()
+	return c.JSON(http.StatusOK, inviteResponse{
+		Code:      code,
+		Link:      link,
+		ExpiresAt: invite.ExpiresAt,
+	})
+}
+
+func (h *handler) sessions(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	sessions, err := h.auth.Sessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	var resp sessionsResponse
+	resp.Sessions = make([]sessionResponse, 0, len(sessions))
+	for i := range sessions {
+		s := &sessions[i]
+		resp.Sessions = append(resp.Sessions, sessionResponse{
+			ID:          s.ID,
+			IP:          s.Device.IP,
+			UserAgent:   s.Device.UserAgent,
+			CreatedAt:   s.CreatedAt,
+			LastRefresh: s.LastRefresh,
+			ExpiresAt:   s.ExpiresAt,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) revokeSession(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Session id is empty")
+	}
+
+	err = h.auth.RevokeSession(c.Request().Context(), userID, id)
+	switch {
+	case errors.Is(err, auth.ErrSessionNotFound):
+		return c.String(http.StatusNotFound, "Session not found")
+	case err != nil:
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusOK, "Session was successfully revoked")
+}
+
+// apiKeys lists keys of the caller, admins can list every key with all=true
+func (h *handler) apiKeys(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	ownerID := userID
+	if c.QueryParam("all") == "true" {
+		if role, _ := h.jwt.ExtractRole(c); role != user.RoleAdmin {
+			return c.String(http.StatusForbidden, "Not enough permissions")
+		}
+		ownerID = ""
+	}
+
+	keys, err := h.keys.Keys(c.Request().Context(), ownerID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	resp := apiKeysResponse{Keys: make([]apiKeyResponse, 0, len(keys))}
+	for i := range keys {
+		resp.Keys = append(resp.Keys, buildAPIKey(&keys[i]))
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) issueAPIKey(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+	role, _ := h.jwt.ExtractRole(c)
+
+	name := c.QueryParam("name")
+	if name == "" {
+		return c.String(http.StatusBadRequest, "Key name is empty")
+	}
+	scopes := strings.Split(c.QueryParam("scopes"), ",")
+	for i := range scopes {
+		if !apikey.ValidScope(scopes[i]) {
+			return c.String(http.StatusBadRequest, "scopes should be a comma separated list of (films:read, films:write, music:control)")
+		}
+	}
+
+	secret, key, err := h.keys.Issue(c.Request().Context(), userID, role, name, scopes)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	resp := buildAPIKey(key)
+	resp.Key = secret
+	return c.JSON(http.StatusOK, resp)
+}
+
+// revokeAPIKey deletes the key of the caller, admins can revoke any key
+func (h *handler) revokeAPIKey(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, "Key id is empty")
+	}
+
+	ownerID := userID
+	if role, _ := h.jwt.ExtractRole(c); role == user.RoleAdmin {
+		ownerID = ""
+	}
+	err = h.keys.Revoke(c.Request().Context(), id, ownerID)
+	switch {
+	case errors.Is(err, apikey.ErrNotFound):
+		return c.String(http.StatusNotFound, "API key not found")
+	case err != nil:
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.String(http.StatusOK, "API key was successfully revoked")
+}
+
+func (h *handler) jwks(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, jwksCacheControl)
+	return c.JSON(http.StatusOK, h.jwt.JWKS())
+}
+
 func (h *handler) callback(c echo.Context) error {
 	ctx := c.Request().Context()
-	userID, username, avatar, err := h.auth.GetDiscordInfo(ctx, c.FormValue("code"), c.FormValue("state"), c.RealIP())
+	info, returnTo, err := h.auth.GetDiscordInfo(ctx, c.FormValue("code"), c.FormValue("state"))
 	switch {
+	case errors.Is(err, auth.ErrBadState):
+		return c.String(http.StatusBadRequest, "Login has expired, try again")
 	case errors.Is(err, auth.ErrUnknownUser):
 		contexts.GetLogger(ctx).Warn("Unknown discord user trying to connect!",
-			zap.String("id", userID), zap.String("username", username))
-		return c.String(http.StatusNotFound, "Unknown user, ask andrei.khodko@gmail.com to add")
+			zap.String("id", info.ID), zap.String("username", info.Username))
+		return c.String(http.StatusNotFound, "Unknown user, ask one of the admins for an invite link")
+	case errors.Is(err, allowlist.ErrBadInvite):
+		contexts.GetLogger(ctx).Warn("Discord user trying to connect with a bad invite",
+			zap.String("id", info.ID), zap.String("username", info.Username))
+		return c.String(http.StatusForbidden, "Invite link is invalid or expired, ask one of the admins for a new one")
 	case err != nil:
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
 
-	accessToken, err := h.jwt.Generate(userID)
+	userID, username := info.ID, info.Username
+	avatar := discordAvatarURL + userID + "/" + info.Avatar
+	// a new user gets the guild role as is, while the role of a known user is only raised by it
+	u, err := h.user.Upsert(ctx, userID, username, avatar, info.Role)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
+	if user.RoleAbove(info.Role, u.Role) {
+		if _, err = h.user.SetRole(ctx, userID, info.Role); err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+	}
 
-	avatar = discordAvatarURL + userID + "/" + avatar
-	if err := h.user.Upsert(ctx, userID, username, avatar); err != nil {
+	code, err := h.auth.LoginCode(userID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	target, err := url.Parse(h.returnURL(returnTo))
+	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
+	query := target.Query()
+	query.Set("code", code)
+	target.RawQuery = query.Encode()
+	return c.Redirect(http.StatusSeeOther, target.String())
+}
 
-	resp := loginResponse{
-		Token:        accessToken,
-		ID:           userID,
-		Username:     username,
-		Avatar:       avatar,
-		Expiration:   time.Now().Add(jwt.TokenTTL),
-		RefreshToken: h.auth.GenerateRefreshToken(userID),
+// returnURL resolves return_to of the login, relative paths belong to the web app
+func (h *handler) returnURL(returnTo string) string {
+	switch {
+	case returnTo == "":
+		return fmt.Sprintf("%s:%s/", h.host, h.web)
+	case strings.HasPrefix(returnTo, "/"):
+		return fmt.Sprintf("%s:%s%s", h.host, h.web, returnTo)
+	default:
+		return returnTo
+	}
+}
+
+func buildAllowed(e *allowlist.Entry) allowedResponse {
+	return allowedResponse{
+		ID:        e.ID,
+		AddedBy:   e.AddedBy,
+		Invite:    e.Invite,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+func buildAPIKey(k *apikey.Key) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		OwnerID:   k.OwnerID,
+		Role:      k.Role,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		LastUsed:  k.LastUsed,
+	}
+}
+
+func device(c echo.Context) auth.Device {
+	return auth.Device{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
 	}
-	return c.Redirect(http.StatusPermanentRedirect, fmt.Sprintf("%s:%s/%s", h.host, h.web, resp.query()))
 }
 
 type loginResponse struct {
 	Token        string    `json:"token"`
 	ID           string    `json:"id,omitempty"`
+	Role         string    `json:"role,omitempty"`
 	Username     string    `json:"username,omitempty"`
 	Avatar       string    `json:"avatar,omitempty"`
-	RefreshToken string    `json:"refresh_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
 	Expiration   time.Time `json:"expiration"`
 }
 
-func (r *loginResponse) query() string {
-	return fmt.Sprintf("?token=%s&id=%s&username=%s&avatar=%s&refresh_token=%s&expiration=%s", r.Token, r.ID, r.Username, r.Avatar, r.RefreshToken, r.Expiration.String())
-}
-
 type userResponse struct {
 	ID       string `json:"id,omitempty"`
 	Username string `json:"username,omitempty"`
 	Avatar   string `json:"avatar,omitempty"`
+	Role     string `json:"role,omitempty"`
 }
 
 type usersResponse struct {
 	Users []userResponse `json:"users,omitempty"`
 }
+
+type allowedResponse struct {
+	ID        string    `json:"id"`
+	AddedBy   string    `json:"added_by,omitempty"`
+	Invite    bool      `json:"invite"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type allowListResponse struct {
+	Users []allowedResponse `json:"users"`
+}
+
+type inviteResponse struct {
+	Code      string    `json:"code"`
+	Link      string    `json:"link"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type sessionResponse struct {
+	ID          string    `json:"id"`
+	IP          string    `json:"ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastRefresh time.Time `json:"last_refresh"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+type apiKeyResponse struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key,omitempty"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	Role      string    `json:"role,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+type apiKeysResponse struct {
+	Keys []apiKeyResponse `json:"keys"`
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+)
+
+const (
+	discordMeURL     = "https://discord.com/api/users/@me"
+	discordGuildsURL = "https://discord.com/api/users/@me/guilds/"
+
+	ModeAllowList = "allowlist"
+	ModeGuild     = "guild"
+
+	scopeGuildMembers = "guilds.members.read"
+)
+
+// GuildConfig admits members of the guild. If Roles are set the member must have at least one of them.
+// RoleMapping maps guild role IDs to application roles, the most privileged one wins.
+// A new user gets the mapped role, for a known user it only raises the role, so the roles set by admins are kept.
+type GuildConfig struct {
+	ID          string            `yaml:"id"`
+	Roles       []string          `yaml:"roles"`
+	RoleMapping map[string]string `yaml:"role_mapping"`
+}
+
+type DiscordUser struct {
+	ID       string
+	Username string
+	Avatar   string
+	Role     string // application role mapped from the guild roles, empty if there is no mapping
+}
+
+type discordOAuthResp struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+type discordMemberResp struct {
+	Roles []string `json:"roles"`
+}
+
+// guildRole checks the membership in the configured guilds and returns the mapped application role
+func (s *service) guildRole(ctx context.Context, client *http.Client) (string, bool, error) {
+	var (
+		role   string
+		member bool
+	)
+	for i := range s.guilds {
+		guild := &s.guilds[i]
+
+		var resp discordMemberResp
+		status, err := getJSON(ctx, client, discordGuildsURL+guild.ID+"/member", &resp)
+		if status == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return "", false, errors.Wrapf(err, "get guild %s member", guild.ID)
+		}
+
+		guildRole, ok := guild.admit(resp.Roles)
+		if !ok {
+			continue
+		}
+		member = true
+		if user.RoleAbove(guildRole, role) {
+			role = guildRole
+		}
+	}
+	return role, member, nil
+}
+
+func (g *GuildConfig) admit(memberRoles []string) (string, bool) {
+	has := make(map[string]struct{}, len(memberRoles))
+	for i := range memberRoles {
+		has[memberRoles[i]] = struct{}{}
+	}
+
+	admitted := len(g.Roles) == 0
+	for i := range g.Roles {
+		if _, ok := has[g.Roles[i]]; ok {
+			admitted = true
+			break
+		}
+	}
+	if !admitted {
+		return "", false
+	}
+
+	var role string
+	for guildRole, appRole := range g.RoleMapping {
+		if _, ok := has[guildRole]; ok && user.RoleAbove(appRole, role) {
+			role = appRole
+		}
+	}
+	return role, true
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "create request")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "do http request")
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return res.StatusCode, errors.Wrap(err, "read response body")
+	}
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, errors.Errorf("response status: %s", res.Status)
+	}
+	return res.StatusCode, errors.Wrap(json.Unmarshal(body, v), "unmarshal response body")
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryStorage struct {
+	mx       *sync.RWMutex
+	tokens   map[string]RefreshToken
+	sessions map[string]Session
+}
+
+func NewMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		mx:       &sync.RWMutex{},
+		tokens:   make(map[string]RefreshToken),
+		sessions: make(map[string]Session),
+	}
+}
+
+func (s *memoryStorage) Get(_ context.Context, id string) (*RefreshToken, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (s *memoryStorage) Set(_ context.Context, token *RefreshToken) error {
+	s.mx.Lock()
+	s.tokens[token.ID] = *token
+	s.sessions[token.FamilyID] = *token.Session()
+	s.mx.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) Rotate(_ context.Context, oldID string, next *RefreshToken) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	old, ok := s.tokens[oldID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Used {
+		return ErrTokenReused
+	}
+	old.Used = true
+	s.tokens[oldID] = old
+	s.tokens[next.ID] = *next
+
+	session, ok := s.sessions[next.FamilyID]
+	if !ok {
+		session = *next.Session()
+	}
+	session.Device = next.Device
+	session.LastRefresh = next.CreatedAt
+	session.ExpiresAt = next.ExpiresAt
+	s.sessions[next.FamilyID] = session
+	return nil
+}
+
+func (s *memoryStorage) Delete(_ context.Context, id string) error {
+	s.mx.Lock()
+	delete(s.tokens, id)
+	s.mx.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) DeleteFamily(_ context.Context, familyID string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for id, t := range s.tokens {
+		if t.FamilyID == familyID {
+			delete(s.tokens, id)
+		}
+	}
+	delete(s.sessions, familyID)
+	return nil
+}
+
+func (s *memoryStorage) DeleteUser(_ context.Context, userID string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for id, t := range s.tokens {
+		if t.UserID == userID {
+			delete(s.tokens, id)
+		}
+	}
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStorage) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	deleted := 0
+	for id, t := range s.tokens {
+		if t.Expired(now) {
+			delete(s.tokens, id)
+			deleted++
+		}
+	}
+	for id, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, id)
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryStorage) Session(_ context.Context, id string) (*Session, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (s *memoryStorage) Sessions(_ context.Context, userID string) ([]Session, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	sessions := make([]Session, 0, approximateSessionsNumber)
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
@@ -2,45 +2,81 @@ package auth
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
-	"io"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	pcache "github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
+
+	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 )
 
 var (
-	ErrBadState     = errors.New("state does not match")
+	ErrBadState     = errors.New("state is unknown or expired")
+	ErrBadCode      = errors.New("login code is unknown or expired")
 	ErrInvalidToken = errors.New("refresh token is invalid")
 	ErrUnknownUser  = errors.New("user unknown")
+	ErrNotFound     = errors.New("refresh token not found")
+	ErrTokenReused  = errors.New("refresh token reuse detected")
+
+	ErrSessionNotFound = errors.New("session not found")
 )
 
 const (
 	authURL  = "https://discord.com/api/oauth2/authorize"
 	tokenURL = "https://discord.com/api/oauth2/token"
-	secret   = "secret"
+	stateTTL = 10 * time.Minute
+	codeTTL  = time.Minute
+
+	defaultRefreshTTL = 30 * 24 * time.Hour
 )
 
 type Config struct {
-	ClientID     string   `yaml:"clientID"`
-	ClientSecret string   `yaml:"clientSecret"`
-	KnownUsers   []string `yaml:"known_users"`
-	Scopes       []string `yaml:"scopes"`
+	ClientID      string        `yaml:"clientID"`
+	ClientSecret  string        `yaml:"clientSecret"`
+	KnownUsers    []string      `yaml:"known_users"` // seed of the allow-list
+	Scopes        []string      `yaml:"scopes"`
+	RefreshTTL    time.Duration `yaml:"refresh_ttl"`
+	Mode          string        `yaml:"mode"`
+	Guilds        []GuildConfig `yaml:"guilds"`
+	ReturnOrigins []string      `yaml:"return_origins"`
+}
+
+type refreshStorage interface {
+	Get(ctx context.Context, id string) (*RefreshToken, error)
+	Set(ctx context.Context, token *RefreshToken) error
+	Rotate(ctx context.Context, oldID string, next *RefreshToken) error
+	Delete(ctx context.Context, id string) error
+	DeleteFamily(ctx context.Context, familyID string) error
+	DeleteUser(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	Session(ctx context.Context, id string) (*Session, error)
+	Sessions(ctx context.Context, userID string) ([]Session, error)
+}
+
+type allowList interface {
+	Allowed(id string) bool
+	Redeem(ctx context.Context, code, userID string) error
 }
 
 type service struct {
-	oauth      *oauth2.Config
-	knownUsers map[string]struct{}
-	refresh    map[string]string // token -> id
-	mx         *sync.RWMutex
+	oauth         *oauth2.Config
+	states        *pcache.Cache // state -> loginState
+	codes         *pcache.Cache // login code -> userID
+	codesLock     sync.Mutex
+	returnOrigins map[string]struct{}
+	guilds        []GuildConfig
+	allowed       allowList
+	refresh       refreshStorage
+	refreshTTL    time.Duration
 }
 
-func New(cfg Config) *service {
+func New(cfg Config, allowed allowList, refresh refreshStorage) *service {
 	s := &service{
 		oauth: &oauth2.Config{
 			ClientID:     cfg.ClientID,
@@ -52,110 +88,254 @@ func New(cfg Config) *service {
 				AuthStyle: oauth2.AuthStyleInParams,
 			},
 		},
-		mx: &sync.RWMutex{},
+		states:        pcache.New(stateTTL, stateTTL),
+		codes:         pcache.New(codeTTL, codeTTL),
+		returnOrigins: make(map[string]struct{}, len(cfg.ReturnOrigins)),
+		allowed:       allowed,
+		refresh:       refresh,
+		refreshTTL:    cfg.RefreshTTL,
 	}
-
-	s.knownUsers = make(map[string]struct{}, len(cfg.KnownUsers))
-	for i := range cfg.KnownUsers {
-		s.knownUsers[cfg.KnownUsers[i]] = struct{}{}
+	for i := range cfg.ReturnOrigins {
+		s.returnOrigins[cfg.ReturnOrigins[i]] = struct{}{}
+	}
+	if cfg.Mode == ModeGuild {
+		s.guilds = cfg.Guilds
+		if !contains(s.oauth.Scopes, scopeGuildMembers) {
+			s.oauth.Scopes = append(s.oauth.Scopes, scopeGuildMembers)
+		}
+	}
+	if s.refreshTTL <= 0 {
+		s.refreshTTL = defaultRefreshTTL
 	}
-
-	s.refresh = make(map[string]string, len(s.knownUsers)*3)
 
 	return s
 }
 
-func (s *service) RedirectURL(redirectURL, key string) string {
-	s.oauth.RedirectURL = redirectURL
-	return s.oauth.AuthCodeURL(generateState(key))
-}
-
-func (s *service) GetDiscordInfo(ctx context.Context, authCode, reqState, key string) (string, string, string, error) {
-	state := generateState(key)
-	if reqState != state {
-		return "", "", "", ErrBadState
+// LoginURL starts the login, returnTo must be checked by ValidReturnTo and invite is redeemed on the callback
+func (s *service) LoginURL(redirectURL, returnTo, invite string) (string, error) {
+	state, err := randomString(stateSize)
+	if err != nil {
+		return "", errors.Wrap(err, "generate state")
 	}
-	token, err := s.oauth.Exchange(ctx, authCode)
+	verifier, err := randomString(verifierSize)
 	if err != nil {
-		return "", "", "", errors.Wrap(err, "exchange auth code for discord token")
+		return "", errors.Wrap(err, "generate code verifier")
 	}
+	s.states.SetDefault(state, loginState{
+		RedirectURL: redirectURL,
+		Verifier:    verifier,
+		ReturnTo:    returnTo,
+		Invite:      invite,
+	})
 
-	res, err := s.oauth.Client(context.Background(), token).Get("https://discord.com/api/users/@me")
-	if err != nil {
-		return "", "", "", errors.Wrap(err, "get my discord info through oauth client")
+	oauth := *s.oauth
+	oauth.RedirectURL = redirectURL
+	return oauth.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// GetDiscordInfo returns the discord user if one is admitted and the return_to of the login.
+// In the guild mode members of the configured guilds are admitted,
+// the rest of users must be in the allow-list or have a valid invite code.
+// Not admitted users are returned together with ErrUnknownUser.
+func (s *service) GetDiscordInfo(ctx context.Context, authCode, reqState string) (*DiscordUser, string, error) {
+	v, ok := s.states.Get(reqState)
+	if !ok {
+		return nil, "", ErrBadState
 	}
-	if res.StatusCode != http.StatusOK {
-		return "", "", "", errors.Wrapf(err, "response status: %s", res.Status)
+	s.states.Delete(reqState)
+	state, ok := v.(loginState)
+	if !ok {
+		return nil, "", ErrBadState
 	}
-	defer func() { _ = res.Body.Close() }()
 
-	body, err := io.ReadAll(res.Body)
+	oauth := *s.oauth
+	oauth.RedirectURL = state.RedirectURL
+	token, err := oauth.Exchange(ctx, authCode, oauth2.SetAuthURLParam("code_verifier", state.Verifier))
 	if err != nil {
-		return "", "", "", errors.Wrap(err, "read response body")
+		return nil, "", errors.Wrap(err, "exchange auth code for discord token")
 	}
 
+	info, err := s.discordUser(ctx, oauth.Client(ctx, token), state.Invite)
+	return info, state.ReturnTo, err
+}
+
+func (s *service) discordUser(ctx context.Context, client *http.Client, invite string) (*DiscordUser, error) {
 	var discordUser discordOAuthResp
-	if err := json.Unmarshal(body, &discordUser); err != nil {
-		return "", "", "", errors.Wrap(err, "unmarshal response body")
+	if _, err := getJSON(ctx, client, discordMeURL, &discordUser); err != nil {
+		return nil, errors.Wrap(err, "get my discord info through oauth client")
+	}
+	info := &DiscordUser{
+		ID:       discordUser.ID,
+		Username: discordUser.Username,
+		Avatar:   discordUser.Avatar,
 	}
 
-	if _, ok := s.knownUsers[discordUser.ID]; !ok {
-		return "", "", "", ErrUnknownUser
+	if len(s.guilds) != 0 {
+		role, member, err := s.guildRole(ctx, client)
+		if err != nil {
+			return nil, errors.Wrap(err, "check guilds membership")
+		}
+		if member {
+			info.Role = role
+			return info, nil
+		}
 	}
 
-	return discordUser.ID, discordUser.Username, discordUser.Avatar, nil
+	if !s.allowed.Allowed(info.ID) {
+		if invite == "" {
+			return info, ErrUnknownUser
+		}
+		if err := s.allowed.Redeem(ctx, invite, info.ID); err != nil {
+			return info, err
+		}
+	}
+
+	return info, nil
 }
 
-func (s *service) GenerateRefreshToken(userID string) string {
-	token := uuid.New().String()
-	s.mx.Lock()
-	s.refresh[token] = userID
-	s.mx.Unlock()
-	return token
+func (s *service) GenerateRefreshToken(ctx context.Context, userID string, device Device) (string, error) {
+	token, next := s.newRefreshToken(userID, uuid.New().String(), device)
+	if err := s.refresh.Set(ctx, next); err != nil {
+		return "", errors.Wrap(err, "save refresh token")
+	}
+	return token, nil
+}
+
+func (s *service) RefreshTTL() time.Duration {
+	return s.refreshTTL
 }
 
-func (s *service) ExpireRefreshToken(token string) {
-	s.mx.Lock()
-	delete(s.refresh, token)
-	s.mx.Unlock()
+// ExpireRefreshToken revokes the whole login the token belongs to
+func (s *service) ExpireRefreshToken(ctx context.Context, token string) error {
+	stored, err := s.refresh.Get(ctx, hashToken(token))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return errors.Wrap(err, "get refresh token")
+	}
+	return errors.Wrap(s.refresh.DeleteFamily(ctx, stored.FamilyID), "delete refresh token family")
 }
 
-func (s *service) ExpireAllRefreshTokens(userID string) {
-	toDelete := make([]string, 0, len(s.refresh))
+func (s *service) ExpireAllRefreshTokens(ctx context.Context, userID string) error {
+	return errors.Wrap(s.refresh.DeleteUser(ctx, userID), "delete user refresh tokens")
+}
+
+func (s *service) ValidateRefreshToken(ctx context.Context, userID, token string, device Device) (string, error) {
+	stored, err := s.refresh.Get(ctx, hashToken(token))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "", ErrInvalidToken
+	case err != nil:
+		return "", errors.Wrap(err, "get refresh token")
+	}
+	if stored.UserID != userID || stored.Expired(time.Now()) {
+		return "", ErrInvalidToken
+	}
+	if stored.Used {
+		return "", s.revokeReused(ctx, stored, device)
+	}
+
+	newToken, next := s.newRefreshToken(userID, stored.FamilyID, device)
+	err = s.refresh.Rotate(ctx, stored.ID, next)
+	switch {
+	case errors.Is(err, ErrTokenReused):
+		return "", s.revokeReused(ctx, stored, device)
+	case errors.Is(err, ErrNotFound):
+		return "", ErrInvalidToken
+	case err != nil:
+		return "", errors.Wrap(err, "rotate refresh token")
+	}
+	return newToken, nil
+}
 
-	s.mx.RLock()
-	for token, id := range s.refresh {
-		if id == userID {
-			toDelete = append(toDelete, token)
+func (s *service) Sessions(ctx context.Context, userID string) ([]Session, error) {
+	sessions, err := s.refresh.Sessions(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user sessions")
+	}
+	now := time.Now()
+	active := sessions[:0]
+	for i := range sessions {
+		if sessions[i].ExpiresAt.After(now) {
+			active = append(active, sessions[i])
 		}
 	}
-	s.mx.RUnlock()
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastRefresh.After(active[j].LastRefresh)
+	})
+	return active, nil
+}
 
-	for i := range toDelete {
-		s.ExpireRefreshToken(toDelete[i])
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.refresh.Session(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
 	}
+	return errors.Wrap(s.refresh.DeleteFamily(ctx, sessionID), "delete session refresh tokens")
 }
 
-func (s *service) ValidateRefreshToken(userID, token string) (string, error) {
-	s.mx.RLock()
-	id, ok := s.refresh[token]
-	s.mx.RUnlock()
-	if !ok || id != userID {
-		return "", ErrInvalidToken
+func (s *service) revokeReused(ctx context.Context, stored *RefreshToken, device Device) error {
+	contexts.GetLogger(ctx).Warn("Refresh token reuse detected, revoking the whole token family",
+		zap.String("userID", stored.UserID),
+		zap.String("familyID", stored.FamilyID),
+		zap.String("ip", device.IP),
+		zap.String("userAgent", device.UserAgent))
+
+	if err := s.refresh.DeleteFamily(ctx, stored.FamilyID); err != nil {
+		return errors.Wrap(err, "delete reused refresh token family")
 	}
+	return ErrTokenReused
+}
 
-	s.ExpireRefreshToken(token)
-	return s.GenerateRefreshToken(userID), nil
+func (s *service) newRefreshToken(userID, familyID string, device Device) (string, *RefreshToken) {
+	token := uuid.New().String()
+	now := time.Now()
+	return token, &RefreshToken{
+		ID:        hashToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		Device:    device,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
 }
 
-func generateState(key string) string {
-	hash := sha256.New()
-	hash.Write([]byte(key))
-	return base64.StdEncoding.EncodeToString(hash.Sum([]byte(secret)))
+// StartPruning periodically removes expired refresh tokens from the storage until ctx is done
+func (s *service) StartPruning(ctx context.Context, interval time.Duration) {
+	logger := contexts.GetLogger(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := s.refresh.DeleteExpired(ctx, time.Now())
+				if err != nil {
+					logger.Error("failed to prune expired refresh tokens", zap.Error(err))
+					continue
+				}
+				if n > 0 {
+					logger.Info("expired refresh tokens pruned", zap.Int("count", n))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 }
 
-type discordOAuthResp struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Avatar   string `json:"avatar"`
+func contains(list []string, v string) bool {
+	for i := range list {
+		if list[i] == v {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestService_ValidateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	s := New(Config{}, nil, NewMemoryStorage())
+
+	token, err := s.GenerateRefreshToken(ctx, "user", Device{})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if _, err := s.ValidateRefreshToken(ctx, "other", token, Device{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for another user, got: %v", err)
+	}
+
+	rotated, err := s.ValidateRefreshToken(ctx, "user", token, Device{})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if rotated == token {
+		t.Errorf("expected rotated token")
+	}
+
+	other, err := s.GenerateRefreshToken(ctx, "user", Device{})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if err := s.ExpireAllRefreshTokens(ctx, "user"); err != nil {
+		t.Fatalf("expire all: %v", err)
+	}
+	for _, token := range []string{rotated, other} {
+		if _, err := s.ValidateRefreshToken(ctx, "user", token, Device{}); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken after logout, got: %v", err)
+		}
+	}
+}
+
+func TestService_ValidateRefreshToken_Reuse(t *testing.T) {
+	ctx := context.Background()
+	s := New(Config{}, nil, NewMemoryStorage())
+
+	stolen, _ := s.GenerateRefreshToken(ctx, "user", Device{})
+	other, _ := s.GenerateRefreshToken(ctx, "user", Device{})
+	rotated, err := s.ValidateRefreshToken(ctx, "user", stolen, Device{})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	if _, err := s.ValidateRefreshToken(ctx, "user", stolen, Device{}); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("expected ErrTokenReused, got: %v", err)
+	}
+	if _, err := s.ValidateRefreshToken(ctx, "user", rotated, Device{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected family to be revoked, got: %v", err)
+	}
+	if _, err := s.ValidateRefreshToken(ctx, "user", other, Device{}); err != nil {
+		t.Errorf("expected another login to stay valid, got: %v", err)
+	}
+}
+
+func TestMemoryStorage_DeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	now := time.Now()
+	_ = storage.Set(ctx, &RefreshToken{ID: "old", ExpiresAt: now.Add(-time.Minute)})
+	_ = storage.Set(ctx, &RefreshToken{ID: "new", ExpiresAt: now.Add(time.Minute)})
+
+	n, err := storage.DeleteExpired(ctx, now)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 deleted, got: %d, %v", n, err)
+	}
+	if _, err := storage.Get(ctx, "new"); err != nil {
+		t.Errorf("expected token to stay: %v", err)
+	}
+}
+
+func TestService_RevokeSession(t *testing.T) {
+	ctx := context.Background()
+	s := New(Config{}, nil, NewMemoryStorage())
+
+	first, _ := s.GenerateRefreshToken(ctx, "user", Device{IP: "1.1.1.1"})
+	second, _ := s.GenerateRefreshToken(ctx, "user", Device{IP: "2.2.2.2"})
+	if _, err := s.ValidateRefreshToken(ctx, "user", first, Device{IP: "3.3.3.3"}); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	sessions, err := s.Sessions(ctx, "user")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got: %d, %v", len(sessions), err)
+	}
+	if sessions[0].Device.IP != "3.3.3.3" {
+		t.Errorf("expected the last refreshed session first, got: %+v", sessions[0])
+	}
+
+	if err := s.RevokeSession(ctx, "other", sessions[1].ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for another user, got: %v", err)
+	}
+	if err := s.RevokeSession(ctx, "user", sessions[1].ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := s.ValidateRefreshToken(ctx, "user", second, Device{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected revoked session token to be invalid, got: %v", err)
+	}
+}
+
+func TestGuildConfig_Admit(t *testing.T) {
+	guild := GuildConfig{
+		Roles: []string{"friends", "mods"},
+		RoleMapping: map[string]string{
+			"friends": "member",
+			"mods":    "admin",
+		},
+	}
+	testCases := []struct {
+		roles    []string
+		role     string
+		admitted bool
+	}{
+		{roles: nil, admitted: false},
+		{roles: []string{"random"}, admitted: false},
+		{roles: []string{"friends"}, role: "member", admitted: true},
+		{roles: []string{"friends", "mods"}, role: "admin", admitted: true},
+	}
+	for _, tc := range testCases {
+		role, ok := guild.admit(tc.roles)
+		if ok != tc.admitted || role != tc.role {
+			t.Errorf("roles %v: expected (%q, %v), got: (%q, %v)", tc.roles, tc.role, tc.admitted, role, ok)
+		}
+	}
+
+	open := GuildConfig{}
+	if role, ok := open.admit(nil); !ok || role != "" {
+		t.Errorf("expected any member to be admitted without a role, got: (%q, %v)", role, ok)
+	}
+}
+
+func TestService_ValidReturnTo(t *testing.T) {
+	s := New(Config{ReturnOrigins: []string{"https://halva.example"}}, nil, NewMemoryStorage())
+	testCases := map[string]bool{
+		"":                                 true,
+		"/films/123?tab=comments":          true,
+		"https://halva.example/films":      true,
+		"//evil.example/films":             false,
+		`/\evil.example`:                   false,
+		"https://evil.example/films":       false,
+		"https://halva.example.evil.com/":  false,
+		"https://user@halva.example/films": false,
+		"javascript:alert(1)":              false,
+	}
+	for returnTo, valid := range testCases {
+		if got := s.ValidReturnTo(returnTo); got != valid {
+			t.Errorf("%q: expected %v, got: %v", returnTo, valid, got)
+		}
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("expected: %s, got: %s", want, got)
+	}
+}
+
+func TestService_ExchangeLoginCode(t *testing.T) {
+	s := New(Config{}, nil, NewMemoryStorage())
+	code, err := s.LoginCode("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := s.ExchangeLoginCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "user" {
+		t.Errorf("expected: user, got: %s", userID)
+	}
+
+	if _, err := s.ExchangeLoginCode(code); !errors.Is(err, ErrBadCode) {
+		t.Errorf("expected ErrBadCode on the second exchange, got: %v", err)
+	}
+}
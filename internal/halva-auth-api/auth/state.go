@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	stateSize     = 32
+	verifierSize  = 32
+	loginCodeSize = 32
+)
+
+// loginState is kept server-side between the login redirect and the discord callback
+type loginState struct {
+	RedirectURL string
+	Verifier    string
+	ReturnTo    string
+	Invite      string
+}
+
+// LoginCode issues a one-time code that the web app exchanges for tokens of the user
+func (s *service) LoginCode(userID string) (string, error) {
+	code, err := randomString(loginCodeSize)
+	if err != nil {
+		return "", errors.Wrap(err, "generate login code")
+	}
+	s.codes.SetDefault(code, userID)
+	return code, nil
+}
+
+// ExchangeLoginCode returns the user of the code, every code is accepted only once
+func (s *service) ExchangeLoginCode(code string) (string, error) {
+	s.codesLock.Lock()
+	defer s.codesLock.Unlock()
+	v, ok := s.codes.Get(code)
+	if !ok {
+		return "", ErrBadCode
+	}
+	s.codes.Delete(code)
+	userID, ok := v.(string)
+	if !ok {
+		return "", ErrBadCode
+	}
+	return userID, nil
+}
+
+func randomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "read random bytes")
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// pkceChallenge is the S256 code challenge of the verifier (RFC 7636)
+func pkceChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// ValidReturnTo allows relative paths of the web app and absolute urls of the configured origins
+func (s *service) ValidReturnTo(returnTo string) bool {
+	if returnTo == "" {
+		return true
+	}
+	if strings.HasPrefix(returnTo, "/") {
+		return !strings.HasPrefix(returnTo, "//") && !strings.Contains(returnTo, `\`)
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return false
+	}
+	_, ok := s.returnOrigins[u.Scheme+"://"+u.Host]
+	return ok
+}
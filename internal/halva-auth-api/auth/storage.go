@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
+)
+
+const approximateSessionsNumber = 10
+
+type storage struct {
+	*firestore.Client
+}
+
+func NewStorage(client *firestore.Client) *storage {
+	return &storage{
+		Client: client,
+	}
+}
+
+func (s *storage) Get(ctx context.Context, id string) (*RefreshToken, error) {
+	doc, err := s.Collection(fire.RefreshCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get refresh token doc")
+	}
+	return ParseRefreshToken(doc)
+}
+
+// Set saves the first token of the login together with its session
+func (s *storage) Set(ctx context.Context, token *RefreshToken) error {
+	_, err := s.Batch().
+		Set(s.Collection(fire.RefreshCollection).Doc(token.ID), token).
+		Set(s.Collection(fire.SessionsCollection).Doc(token.FamilyID), token.Session()).
+		Commit(ctx)
+	return errors.Wrap(err, "commit set refresh token batch")
+}
+
+// Rotate marks the old token as used, saves the next one and refreshes the session in a single transaction
+func (s *storage) Rotate(ctx context.Context, oldID string, next *RefreshToken) error {
+	var (
+		oldRef     = s.Collection(fire.RefreshCollection).Doc(oldID)
+		nextRef    = s.Collection(fire.RefreshCollection).Doc(next.ID)
+		sessionRef = s.Collection(fire.SessionsCollection).Doc(next.FamilyID)
+	)
+	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(oldRef)
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return ErrNotFound
+		case err != nil:
+			return errors.Wrap(err, "get refresh token doc")
+		}
+
+		old, err := ParseRefreshToken(doc)
+		if err != nil {
+			return errors.Wrap(err, "parse refresh token doc")
+		}
+		if old.Used {
+			return ErrTokenReused
+		}
+
+		old.Used = true
+		if err := tx.Set(oldRef, old); err != nil {
+			return errors.Wrap(err, "tx set old refresh token doc")
+		}
+		if err := tx.Set(nextRef, next); err != nil {
+			return errors.Wrap(err, "tx set next refresh token doc")
+		}
+		err = tx.Set(sessionRef, map[string]interface{}{
+			"user_id":      next.UserID,
+			"device":       next.Device,
+			"last_refresh": next.CreatedAt,
+			"expires_at":   next.ExpiresAt,
+		}, firestore.MergeAll)
+		return errors.Wrap(err, "tx set session doc")
+	})
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrTokenReused) {
+		return err
+	}
+	return errors.Wrap(err, "run rotate refresh token transaction")
+}
+
+func (s *storage) Delete(ctx context.Context, id string) error {
+	_, err := s.Collection(fire.RefreshCollection).Doc(id).Delete(ctx)
+	return errors.Wrap(err, "delete refresh token doc")
+}
+
+func (s *storage) DeleteFamily(ctx context.Context, familyID string) error {
+	_, err := s.deleteAll(ctx, s.Collection(fire.RefreshCollection).Where("family_id", "==", familyID))
+	if err != nil {
+		return errors.Wrap(err, "delete refresh token family")
+	}
+	_, err = s.Collection(fire.SessionsCollection).Doc(familyID).Delete(ctx)
+	return errors.Wrap(err, "delete session doc")
+}
+
+func (s *storage) DeleteUser(ctx context.Context, userID string) error {
+	_, err := s.deleteAll(ctx, s.Collection(fire.RefreshCollection).Where("user_id", "==", userID))
+	if err != nil {
+		return errors.Wrap(err, "delete user refresh tokens")
+	}
+	_, err = s.deleteAll(ctx, s.Collection(fire.SessionsCollection).Where("user_id", "==", userID))
+	return errors.Wrap(err, "delete user sessions")
+}
+
+func (s *storage) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	n, err := s.deleteAll(ctx, s.Collection(fire.RefreshCollection).Where("expires_at", "<=", now))
+	if err != nil {
+		return n, errors.Wrap(err, "delete expired refresh tokens")
+	}
+	_, err = s.deleteAll(ctx, s.Collection(fire.SessionsCollection).Where("expires_at", "<=", now))
+	return n, errors.Wrap(err, "delete expired sessions")
+}
+
+func (s *storage) Session(ctx context.Context, id string) (*Session, error) {
+	doc, err := s.Collection(fire.SessionsCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get session doc")
+	}
+	return ParseSession(doc)
+}
+
+func (s *storage) Sessions(ctx context.Context, userID string) ([]Session, error) {
+	sessions := make([]Session, 0, approximateSessionsNumber)
+	iter := s.Collection(fire.SessionsCollection).Where("user_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "get next iterator")
+		}
+		session, err := ParseSession(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse session doc")
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+func (s *storage) deleteAll(ctx context.Context, query firestore.Query) (int, error) {
+	var (
+		deleted int
+		batch   = s.Batch()
+		size    int
+	)
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, errors.Wrap(err, "get next iterator")
+		}
+
+		batch.Delete(doc.Ref)
+		size++
+		if size == fire.BatchSize {
+			if _, err := batch.Commit(ctx); err != nil {
+				return deleted, errors.Wrap(err, "commit delete batch")
+			}
+			deleted += size
+			batch, size = s.Batch(), 0
+		}
+	}
+
+	if size == 0 {
+		return deleted, nil
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return deleted, errors.Wrap(err, "commit delete batch")
+	}
+	return deleted + size, nil
+}
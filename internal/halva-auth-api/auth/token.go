@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+type Device struct {
+	IP        string `firestore:"ip,omitempty"`
+	UserAgent string `firestore:"user_agent,omitempty"`
+}
+
+// RefreshToken is stored by the hash of the token, the token itself is never persisted.
+// All tokens rotated from one login share the FamilyID, rotated tokens are kept as Used until expiration
+// so that their reuse can be detected.
+type RefreshToken struct {
+	ID        string    `firestore:"-"`
+	UserID    string    `firestore:"user_id"`
+	FamilyID  string    `firestore:"family_id"`
+	Used      bool      `firestore:"used"`
+	Device    Device    `firestore:"device"`
+	CreatedAt time.Time `firestore:"created_at"`
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// Session describes one login of the user, its ID is the FamilyID of the login refresh tokens
+type Session struct {
+	ID          string    `firestore:"-"`
+	UserID      string    `firestore:"user_id"`
+	Device      Device    `firestore:"device"`
+	CreatedAt   time.Time `firestore:"created_at"`
+	LastRefresh time.Time `firestore:"last_refresh"`
+	ExpiresAt   time.Time `firestore:"expires_at"`
+}
+
+func (t *RefreshToken) Session() *Session {
+	return &Session{
+		ID:          t.FamilyID,
+		UserID:      t.UserID,
+		Device:      t.Device,
+		CreatedAt:   t.CreatedAt,
+		LastRefresh: t.CreatedAt,
+		ExpiresAt:   t.ExpiresAt,
+	}
+}
+
+func (t *RefreshToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.After(now)
+}
+
+func ParseRefreshToken(doc *firestore.DocumentSnapshot) (*RefreshToken, error) {
+	var t RefreshToken
+	if err := doc.DataTo(&t); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	t.ID = doc.Ref.ID
+	return &t, nil
+}
+
+func ParseSession(doc *firestore.DocumentSnapshot) (*Session, error) {
+	var s Session
+	if err := doc.DataTo(&s); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	s.ID = doc.Ref.ID
+	return &s, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -19,20 +19,28 @@ type cacheService interface {
 }
 
 type storageService interface {
-	Upsert(ctx context.Context, user *user.Item) error
+	Upsert(ctx context.Context, user *user.Item) (*user.Item, error)
+	SetRole(ctx context.Context, id, role string) error
 	All(ctx context.Context) (user.Items, error)
 }
 
 type service struct {
 	cache   cacheService
 	storage storageService
+	admins  map[string]struct{}
 }
 
-func New(cache cacheService, storage storageService) *service {
-	return &service{
+// New creates user service, admins are granted the admin role on their first login
+func New(cache cacheService, storage storageService, admins []string) *service {
+	s := &service{
 		cache:   cache,
 		storage: storage,
+		admins:  make(map[string]struct{}, len(admins)),
 	}
+	for i := range admins {
+		s.admins[admins[i]] = struct{}{}
+	}
+	return s
 }
 
 func (s *service) FillCache(ctx context.Context) error {
@@ -40,18 +48,41 @@ func (s *service) FillCache(ctx context.Context) error {
 	return err
 }
 
-func (s *service) Upsert(ctx context.Context, id, username, avatar string) error {
+// Upsert saves the profile of the user, role is given to a new user, it is member if empty
+func (s *service) Upsert(ctx context.Context, id, username, avatar, role string) (*user.Item, error) {
+	if role == "" {
+		role = user.RoleMember
+	}
 	u := &user.Item{
 		ID:       id,
 		Username: username,
 		Avatar:   avatar,
+		Role:     role,
+	}
+	if _, ok := s.admins[id]; ok {
+		u.Role = user.RoleAdmin
 	}
-	err := s.storage.Upsert(ctx, u)
+
+	u, err := s.storage.Upsert(ctx, u)
 	if err != nil {
-		return errors.Wrap(err, "upsert user to storage")
+		return nil, errors.Wrap(err, "upsert user to storage")
+	}
+	s.cache.Set(u)
+	return u, nil
+}
+
+func (s *service) SetRole(ctx context.Context, id, role string) (*user.Item, error) {
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.SetRole(ctx, id, role); err != nil {
+		return nil, errors.Wrap(err, "set user role in storage")
 	}
+	u.Role = role
 	s.cache.Set(u)
-	return nil
+	return u, nil
 }
 
 func (s *service) Get(ctx context.Context, id string) (*user.Item, error) {
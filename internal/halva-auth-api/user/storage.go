@@ -25,12 +25,17 @@ func NewStorage(client *firestore.Client) *storage {
 	}
 }
 
-func (s *storage) Upsert(ctx context.Context, new *user.Item) error {
-	userRef := s.Collection(fire.UsersCollection).Doc(new.ID)
+// Upsert updates the profile of the user, the role of the new item is set only if the user has none yet
+func (s *storage) Upsert(ctx context.Context, new *user.Item) (*user.Item, error) {
+	var (
+		result  *user.Item
+		userRef = s.Collection(fire.UsersCollection).Doc(new.ID)
+	)
 	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		userDoc, err := tx.Get(userRef)
 		switch {
 		case status.Code(err) == codes.NotFound:
+			result = new
 			return errors.Wrap(tx.Set(userRef, new), "tx set user doc")
 		case err != nil:
 			return errors.Wrap(err, "get user doc")
@@ -42,11 +47,25 @@ func (s *storage) Upsert(ctx context.Context, new *user.Item) error {
 		}
 		old.Username = new.Username
 		old.Avatar = new.Avatar
+		if old.Role == "" {
+			old.Role = new.Role
+		}
 
+		result = old
 		return errors.Wrap(tx.Set(userRef, old), "tx set user doc")
 	})
+	if err != nil {
+		return nil, errors.Wrap(err, "run upsert user transaction")
+	}
+	return result, nil
+}
 
-	return errors.Wrap(err, "run upsert user transaction")
+func (s *storage) SetRole(ctx context.Context, id, role string) error {
+	_, err := s.Collection(fire.UsersCollection).Doc(id).Update(ctx, []firestore.Update{{Path: "role", Value: role}})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "update user role")
 }
 
 func (s *storage) All(ctx context.Context) (user.Items, error) {
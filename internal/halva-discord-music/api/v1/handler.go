@@ -18,6 +18,8 @@ import (
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/music/player"
 	"github.com/HalvaPovidlo/halva-services/internal/halva-discord-music/music/search"
 	"github.com/HalvaPovidlo/halva-services/internal/pkg/song"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
 )
 
@@ -41,11 +43,14 @@ type discordClient interface {
 
 type jwtService interface {
 	Authorization(next echo.HandlerFunc) echo.HandlerFunc
+	RequireRole(roles ...string) echo.MiddlewareFunc
+	RequireScope(scope string) echo.MiddlewareFunc
 	ExtractUserID(c echo.Context) (string, error)
+	ExtractRole(c echo.Context) (string, error)
 }
 
 type socketManager interface {
-	Open(c echo.Context, userID discord.UserID) error
+	Open(c echo.Context, userID discord.UserID, role string) error
 	Write(data []byte, userID discord.UserID, id uuid.UUID) error
 	WriteAll(data []byte) error
 	ReadChan() <-chan socket.Data
@@ -112,14 +117,15 @@ func New(ctx context.Context, client discordClient, searcher searcher, player pl
 
 func (h *handler) RegisterRoutes(e *echo.Echo) {
 	e.GET("/api/v1/status", h.open)
-	e.GET("/api/v1/control", h.open, h.jwt.Authorization)
+	e.GET("/api/v1/control", h.open, h.jwt.Authorization, h.jwt.RequireScope(apikey.ScopeMusicControl), h.jwt.RequireRole(user.RoleAdmin, user.RoleMember))
 }
 
 func (h *handler) open(c echo.Context) error {
 	id, _ := h.jwt.ExtractUserID(c)
 	if id == "" {
-		return h.socket.Open(c, 0)
+		return h.socket.Open(c, 0, "")
 	}
+	role, _ := h.jwt.ExtractRole(c)
 
 	parsed, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
@@ -127,7 +133,7 @@ func (h *handler) open(c echo.Context) error {
 	}
 	userID := discord.UserID(parsed)
 
-	return h.socket.Open(c, userID)
+	return h.socket.Open(c, userID, role)
 }
 
 func (h *handler) readSocket(ctx context.Context) {
@@ -149,7 +155,7 @@ func (h *handler) readSocket(ctx context.Context) {
 			logger := contexts.GetLogger(ctx).With(zap.Stringer("userID", data.UserID), zap.Stringer("socketID", data.SocketID))
 
 			logger.Info("process command from socket")
-			if err := h.processCommand(ctx, &cmd, data.UserID); err != nil {
+			if err := h.processCommand(ctx, &cmd, data.UserID, data.Role); err != nil {
 				logger.Error("failed to process command from socket", zap.Error(err))
 				if err := h.writeError(err, data.UserID, data.SocketID); err != nil {
 					logger.Error("failed to write error message to socket", zap.Error(err))
@@ -162,7 +168,11 @@ func (h *handler) readSocket(ctx context.Context) {
 	}
 }
 
-func (h *handler) processCommand(ctx context.Context, cmd *command, userID discord.UserID) error {
+func (h *handler) processCommand(ctx context.Context, cmd *command, userID discord.UserID, role string) error {
+	if cmd.Type == commandDisconnect && role != user.RoleAdmin {
+		return errors.New("only admins can disconnect the bot")
+	}
+
 	voiceState, err := h.client.VoiceState(pds.HalvaGuildID, userID)
 	if err != nil {
 		return errors.Wrap(err, "get voice state")
@@ -24,6 +24,7 @@ type Conn interface {
 type Data struct {
 	Bytes    []byte
 	UserID   discord.UserID
+	Role     string
 	SocketID uuid.UUID
 }
 
@@ -43,7 +44,7 @@ func NewManager(ctx context.Context) *manager {
 	}
 }
 
-func (m *manager) readSocket(ctx context.Context, userID discord.UserID, socketID uuid.UUID, socket Conn) {
+func (m *manager) readSocket(ctx context.Context, userID discord.UserID, role string, socketID uuid.UUID, socket Conn) {
 	id := key(userID, socketID)
 	defer func() {
 		m.mx.Lock()
@@ -66,13 +67,14 @@ func (m *manager) readSocket(ctx context.Context, userID discord.UserID, socketI
 			m.read <- Data{
 				Bytes:    data,
 				UserID:   userID,
+				Role:     role,
 				SocketID: socketID,
 			}
 		}
 	}
 }
 
-func (m *manager) Open(c echo.Context, userID discord.UserID) error {
+func (m *manager) Open(c echo.Context, userID discord.UserID, role string) error {
 	socket, err := psocket.NewSocket(m.ctx, c)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, fmt.Errorf("start new socket: %+w", err).Error())
@@ -87,7 +89,7 @@ func (m *manager) Open(c echo.Context, userID discord.UserID) error {
 	m.sockets[id] = socket
 	m.mx.Unlock()
 
-	m.readSocket(m.ctx, userID, socketID, socket)
+	m.readSocket(m.ctx, userID, role, socketID, socket)
 	return c.String(http.StatusOK, "socket successfully closed")
 }
 
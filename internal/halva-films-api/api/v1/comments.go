@@ -0,0 +1,180 @@
+package apiv1
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	films "github.com/HalvaPovidlo/halva-services/internal/halva-films-api/film"
+	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+)
+
+const (
+	errCommentNotFound = "comment not found"
+	maxEmojiLength     = 8 // runes, emojis with modifiers take several
+)
+
+// comment adds the comment to the film, a reply if parent_id is set
+func (h *handler) comment(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, errEmptyID)
+	}
+
+	var req commentRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	film, err := h.film.Comment(c.Request().Context(), userID, id, req.Text, req.ParentID, req.Spoiler != nil && *req.Spoiler)
+	return h.respondComment(c, userID, film, err)
+}
+
+// editComment replaces the text of the comment and its spoiler flag if given, only its author or an admin can do it
+func (h *handler) editComment(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+	role, _ := h.jwt.ExtractRole(c)
+
+	var req commentRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		return c.String(http.StatusBadRequest, "text is empty, delete the comment instead")
+	}
+
+	film, err := h.film.EditComment(c.Request().Context(), userID, c.Param("id"), c.Param("comment"), req.Text, req.Spoiler, role == user.RoleAdmin)
+	return h.respondComment(c, userID, film, err)
+}
+
+// deleteComment removes the comment, only its author or an admin can do it
+func (h *handler) deleteComment(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+	role, _ := h.jwt.ExtractRole(c)
+
+	film, err := h.film.DeleteComment(c.Request().Context(), userID, c.Param("id"), c.Param("comment"), role == user.RoleAdmin)
+	return h.respondComment(c, userID, film, err)
+}
+
+// react toggles the emoji reaction of the caller on the comment
+func (h *handler) react(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	emoji := c.QueryParam("emoji")
+	if !validEmoji(emoji) {
+		return c.String(http.StatusBadRequest, "emoji should be a single emoji")
+	}
+
+	film, err := h.film.React(c.Request().Context(), userID, c.Param("id"), c.Param("comment"), emoji)
+	return h.respondComment(c, userID, film, err)
+}
+
+// markRead remembers that the caller has read the comments of the film, they are no longer counted as unread
+func (h *handler) markRead(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	id := c.Param("id")
+	at, err := h.film.MarkRead(c.Request().Context(), userID, id)
+	switch {
+	case errors.Is(err, films.ErrNotFound):
+		return c.String(http.StatusNotFound, errFilmNotFound)
+	case err != nil:
+		return err
+	}
+	return c.JSON(http.StatusOK, readResponse{FilmID: id, ReadAt: at})
+}
+
+func (h *handler) respondComment(c echo.Context, userID string, film *pfilm.Item, err error) error {
+	switch {
+	case errors.Is(err, films.ErrNotFound):
+		return c.String(http.StatusNotFound, errFilmNotFound)
+	case errors.Is(err, films.ErrNoComment):
+		return c.String(http.StatusNotFound, errCommentNotFound)
+	case errors.Is(err, films.ErrForbidden):
+		return c.String(http.StatusForbidden, "Only the author can change the comment")
+	case err != nil:
+		return err
+	}
+	return c.JSON(http.StatusOK, build(film, userID, true, ratingOf(c)))
+}
+
+// validEmoji accepts short strings without letters, digits and spaces
+func validEmoji(emoji string) bool {
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return false
+	}
+	for _, r := range emoji {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildComment hides the text of a spoiler from those who have not seen the film
+func buildComment(comment *pfilm.Comment, userID string, watched bool) commentResp {
+	resp := commentResp{
+		ID:        comment.ID,
+		ParentID:  comment.ParentID,
+		UserID:    comment.UserID,
+		Text:      comment.Text,
+		Reactions: comment.Reactions,
+		Deleted:   comment.Deleted,
+		Spoiler:   comment.Spoiler,
+		CreatedAt: comment.CreatedAt,
+	}
+	if comment.Spoiler && !watched && comment.UserID != userID {
+		resp.Text, resp.Hidden = "", true
+	}
+	if !comment.EditedAt.IsZero() {
+		resp.EditedAt = &comment.EditedAt
+	}
+	return resp
+}
+
+type commentResp struct {
+	ID        string              `json:"id"`
+	ParentID  string              `json:"parent_id,omitempty"`
+	UserID    string              `json:"user_id"`
+	Text      string              `json:"text"`
+	Reactions map[string][]string `json:"reactions,omitempty"`
+	Deleted   bool                `json:"deleted,omitempty"`
+	Spoiler   bool                `json:"spoiler,omitempty"`
+	Hidden    bool                `json:"hidden,omitempty"` // the spoiler text is shown after the film is scored or watched
+	CreatedAt time.Time           `json:"created_at"`
+	EditedAt  *time.Time          `json:"edited_at,omitempty"`
+}
+
+type commentRequest struct {
+	Text     string `json:"text"`
+	ParentID string `json:"parent_id"`
+	Spoiler  *bool  `json:"spoiler"`
+}
+
+type readResponse struct {
+	FilmID string    `json:"film_id"`
+	ReadAt time.Time `json:"read_at"`
+}
@@ -0,0 +1,47 @@
+package apiv1
+
+import (
+	"testing"
+
+	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+func TestValidEmoji(t *testing.T) {
+	testCases := map[string]bool{
+		"👍":         true,
+		"❤️":        true,
+		"👍🏽":        true,
+		"🇷🇺":        true,
+		"":          false,
+		"lol":       false,
+		"👍 👍":       false,
+		"👍1":        false,
+		"🔥🔥🔥🔥🔥🔥🔥🔥🔥": false,
+	}
+	for emoji, valid := range testCases {
+		if got := validEmoji(emoji); got != valid {
+			t.Errorf("%q: expected %v, got %v", emoji, valid, got)
+		}
+	}
+}
+
+func TestBuildComment(t *testing.T) {
+	f := &pfilm.Item{
+		Scores:   map[string]pfilm.Score{"scored": pfilm.GoodScore},
+		Statuses: map[string]pfilm.Status{"watched": pfilm.StatusWatched, "want": pfilm.StatusWant},
+		Comments: []pfilm.Comment{{ID: "1", UserID: "author", Text: "he was dead all along", Spoiler: true}},
+	}
+	testCases := map[string]bool{
+		"scored":  false,
+		"watched": false,
+		"author":  false,
+		"want":    true,
+		"other":   true,
+	}
+	for userID, hidden := range testCases {
+		resp := buildComment(&f.Comments[0], userID, f.Watched(userID))
+		if resp.Hidden != hidden || (resp.Text == "") != hidden || !resp.Spoiler {
+			t.Errorf("%s: expected hidden %v, got: %+v", userID, hidden, resp)
+		}
+	}
+}
@@ -5,78 +5,162 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 
 	films "github.com/HalvaPovidlo/halva-services/internal/halva-films-api/film"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/imdb"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/kinopoisk"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/planner"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/provider"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/tmdb"
 	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+	"github.com/HalvaPovidlo/halva-services/pkg/apikey"
 )
 
 const (
-	SortLexicographic     = "lexicographic"
-	SortRatingKinopoisk   = "kinopoisk"
-	SortRatingImdb        = "imdb"
-	SortRatingHalva       = "halva"
-	SortRatingSum         = "sum"
-	SortRatingAverage     = "average"
-	SortRatingScoreNumber = "score_number"
-	SortCreatedAt         = "created"
-	SortUpdatedAt         = "updated"
-
 	errEmptyID      = "empty id"
 	errFilmNotFound = "film not found"
+	errBadStatus    = "status should be in (want, watching, watched, dropped)"
+
+	ratingKey = "rating_strategy"
 )
 
 type filmService interface {
-	New(ctx context.Context, userID, url string, score pfilm.Score) (*pfilm.Item, error)
+	New(ctx context.Context, userID, url string, score *pfilm.Score, status pfilm.Status) (*pfilm.Item, error)
+	NewManual(ctx context.Context, userID string, item *pfilm.Item, score *pfilm.Score, status pfilm.Status) (*pfilm.Item, error)
+	Lookup(ctx context.Context, query string) ([]films.Candidate, error)
 	Get(ctx context.Context, url string) (*pfilm.Item, error)
 	All(ctx context.Context) (pfilm.Items, error)
+	Search(ctx context.Context, q *films.Query) (pfilm.Items, error)
 	Score(ctx context.Context, userID, url string, score pfilm.Score) (*pfilm.Item, error)
 	RemoveScore(ctx context.Context, userID, url string) (*pfilm.Item, error)
+	SetStatus(ctx context.Context, userID, url string, status pfilm.Status) (*pfilm.Item, error)
+	RemoveStatus(ctx context.Context, userID, url string) (*pfilm.Item, error)
+	Refresh(ctx context.Context, url string) (*pfilm.Item, error)
 	User(ctx context.Context, userID string) (pfilm.Items, error)
-	Comment(ctx context.Context, userID, url, text string) (*pfilm.Item, error)
+	Comment(ctx context.Context, userID, url, text, parentID string, spoiler bool) (*pfilm.Item, error)
+	EditComment(ctx context.Context, userID, url, commentID, text string, spoiler *bool, admin bool) (*pfilm.Item, error)
+	DeleteComment(ctx context.Context, userID, url, commentID string, admin bool) (*pfilm.Item, error)
+	React(ctx context.Context, userID, url, commentID, emoji string) (*pfilm.Item, error)
+	MarkRead(ctx context.Context, userID, url string) (time.Time, error)
+	Unread(ctx context.Context, userID string) (map[string]int, error)
+}
+
+type ratingService interface {
+	Get(name string) (pfilm.RatingStrategy, error)
+	All() []pfilm.RatingStrategy
+}
+
+type plannerService interface {
+	Candidates(ctx context.Context, opts planner.Options) ([]planner.Candidate, error)
+	Pick(ctx context.Context, opts planner.Options) (*planner.Candidate, []planner.Candidate, error)
 }
 
 type jwtService interface {
 	Authorization(next echo.HandlerFunc) echo.HandlerFunc
+	RequireRole(roles ...string) echo.MiddlewareFunc
+	RequireScope(scope string) echo.MiddlewareFunc
 	ExtractUserID(c echo.Context) (string, error)
+	ExtractRole(c echo.Context) (string, error)
 }
 
 type handler struct {
 	film        filmService
+	planner     plannerService
+	session     sessionService
+	ratings     ratingService
+	stats       statsService
+	recommender recommenderService
 	jwt         jwtService
 	defaultSort string
 	tokenTTL    time.Duration
 }
 
-func New(filmService filmService, jwtService jwtService, defaultSort string) *handler {
+func New(filmService filmService, plannerService plannerService, sessionService sessionService, ratings ratingService, statsService statsService, recommenderService recommenderService, jwtService jwtService, defaultSort string) *handler {
 	return &handler{
 		jwt:         jwtService,
 		film:        filmService,
+		planner:     plannerService,
+		session:     sessionService,
+		ratings:     ratings,
+		stats:       statsService,
+		recommender: recommenderService,
 		defaultSort: defaultSort,
 	}
 }
 
 func (h *handler) RegisterRoutes(e *echo.Echo) {
-	e.GET("/api/v1/public/films/:id/get", h.get)
-	e.GET("/api/v1/public/films/all", h.all)
+	e.GET("/api/v1/public/films/:id/get", h.get, h.ratingParam)
+	e.GET("/api/v1/public/films/all", h.all, h.ratingParam)
+
+	member := h.jwt.RequireRole(user.RoleAdmin, user.RoleMember)
+	read, write := h.jwt.RequireScope(apikey.ScopeFilmsRead), h.jwt.RequireScope(apikey.ScopeFilmsWrite)
+	e.POST("/api/v1/films/new", h.new, h.jwt.Authorization, write, member, h.ratingParam)
+	e.POST("/api/v1/films/manual", h.manual, h.jwt.Authorization, write, member, h.ratingParam)
+	e.GET("/api/v1/films/lookup", h.lookup, h.jwt.Authorization, read)
+	e.GET("/api/v1/films/:id/get", h.get, h.jwt.Authorization, read, h.ratingParam)
+	e.GET("/api/v1/films/all", h.all, h.jwt.Authorization, read, h.ratingParam)
+	e.GET("/api/v1/films/my", h.my, h.jwt.Authorization, read, h.ratingParam)
+	e.GET("/api/v1/films/search", h.search, h.jwt.Authorization, read, h.ratingParam)
+	e.GET("/api/v1/films/plan", h.plan, h.jwt.Authorization, read, h.ratingParam)
+	e.POST("/api/v1/films/plan/pick", h.pick, h.jwt.Authorization, write, member, h.ratingParam)
+	e.GET("/api/v1/films/stats", h.statistics, h.jwt.Authorization, read, h.ratingParam)
+	e.GET("/api/v1/films/recommendations", h.recommendations, h.jwt.Authorization, read, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/score", h.score, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/unscore", h.removeScore, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/status", h.status, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/unstatus", h.removeStatus, h.jwt.Authorization, write, member, h.ratingParam)
+	e.POST("/api/v1/films/:id/comment", h.comment, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/comments/:comment", h.editComment, h.jwt.Authorization, write, member, h.ratingParam)
+	e.DELETE("/api/v1/films/:id/comments/:comment", h.deleteComment, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/comments/:comment/react", h.react, h.jwt.Authorization, write, member, h.ratingParam)
+	e.PATCH("/api/v1/films/:id/read", h.markRead, h.jwt.Authorization, write)
+	e.POST("/api/v1/films/:id/refresh", h.refresh, h.jwt.Authorization, write, h.jwt.RequireRole(user.RoleAdmin), h.ratingParam)
+
+	e.POST("/api/v1/sessions", h.createSession, h.jwt.Authorization, write, member)
+	e.GET("/api/v1/sessions", h.sessions, h.jwt.Authorization, read)
+	e.GET("/api/v1/sessions/reminders", h.reminders, h.jwt.Authorization, read)
+	e.GET("/api/v1/sessions/:id", h.getSession, h.jwt.Authorization, read)
+	e.PATCH("/api/v1/sessions/:id/rsvp", h.rsvp, h.jwt.Authorization, write, member)
+	e.DELETE("/api/v1/sessions/:id", h.deleteSession, h.jwt.Authorization, write, member)
+}
+
+// ratingParam resolves the rating strategy of the request before any changes are made
+func (h *handler) ratingParam(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		selected, err := h.ratings.Get(c.QueryParam("rating"))
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		c.Set(ratingKey, &ratingView{selected: selected, all: h.ratings.All()})
+		return next(c)
+	}
+}
+
+// ratingView is the rating strategy chosen for the response and all strategies computed along with it
+type ratingView struct {
+	selected pfilm.RatingStrategy
+	all      []pfilm.RatingStrategy
+}
 
-	e.POST("/api/v1/films/new", h.new, h.jwt.Authorization)
-	e.GET("/api/v1/films/:id/get", h.get, h.jwt.Authorization)
-	e.GET("/api/v1/films/all", h.all, h.jwt.Authorization)
-	e.GET("/api/v1/films/my", h.my, h.jwt.Authorization)
-	e.PATCH("/api/v1/films/:id/score", h.score, h.jwt.Authorization)
-	e.PATCH("/api/v1/films/:id/unscore", h.removeScore, h.jwt.Authorization)
-	e.POST("/api/v1/films/:id/comment", h.comment, h.jwt.Authorization)
+func ratingOf(c echo.Context) *ratingView {
+	rv, _ := c.Get(ratingKey).(*ratingView)
+	return rv
 }
 
+// new adds the film by the link or by the provider ID like the one returned by lookup
 func (h *handler) new(c echo.Context) error {
 	url := c.QueryParam("url")
-	scoreStr := c.QueryParam("score")
-	if url == "" || scoreStr == "" {
-		return c.String(http.StatusBadRequest, "url or score param is empty")
+	if url == "" {
+		url = c.QueryParam("id")
+	}
+	if url == "" {
+		return c.String(http.StatusBadRequest, "both url and id params are empty")
 	}
 
 	userID, err := h.jwt.ExtractUserID(c)
@@ -84,20 +168,137 @@ func (h *handler) new(c echo.Context) error {
 		return c.String(http.StatusUnauthorized, err.Error())
 	}
 
-	score, err := strconv.Atoi(scoreStr)
-	if err != nil || score < -1 || score > 2 {
-		return c.String(http.StatusBadRequest, "score should be in (-1, 0, 1, 2)")
+	score, status, msg := scoreAndStatus(c)
+	if msg != "" {
+		return c.String(http.StatusBadRequest, msg)
 	}
 
-	film, err := h.film.New(c.Request().Context(), userID, url, pfilm.Score(score))
+	film, err := h.film.New(c.Request().Context(), userID, url, score, status)
 	switch {
 	case errors.Is(err, films.ErrAlreadyExists):
 		return c.String(http.StatusBadRequest, "Film already exists")
 	case err != nil:
+		return providerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
+}
+
+// lookup finds films on the provider sites by keywords and marks the ones already added
+func (h *handler) lookup(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return c.String(http.StatusBadRequest, "q param is empty")
+	}
+
+	candidates, err := h.film.Lookup(c.Request().Context(), query)
+	if err != nil {
+		return providerError(c, err)
+	}
+
+	resp := lookupResponse{Candidates: make([]lookupCandidateResponse, 0, len(candidates))}
+	for i := range candidates {
+		f := &candidates[i].Film
+		resp.Candidates = append(resp.Candidates, lookupCandidateResponse{
+			ID:              f.ID,
+			Provider:        f.Provider,
+			Title:           f.Title,
+			TitleOriginal:   f.TitleOriginal,
+			Poster:          f.Poster,
+			Description:     f.Description,
+			URL:             f.URL,
+			RatingKinopoisk: f.RatingKinopoisk,
+			Year:            f.Year,
+			Serial:          f.Serial,
+			Genres:          f.Genres,
+			Added:           candidates[i].AddedID != "",
+			FilmID:          candidates[i].AddedID,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// manual adds the film which no provider knows with the metadata from the request body
+func (h *handler) manual(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	score, status, msg := scoreAndStatus(c)
+	if msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+
+	var req manualRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return c.String(http.StatusBadRequest, "title is empty")
+	}
+
+	film, err := h.film.NewManual(c.Request().Context(), userID, &pfilm.Item{
+		Title:         strings.TrimSpace(req.Title),
+		TitleOriginal: strings.TrimSpace(req.TitleOriginal),
+		Poster:        req.Poster,
+		Director:      req.Director,
+		Description:   req.Description,
+		URL:           req.URL,
+		ImdbID:        req.ImdbID,
+		Year:          req.Year,
+		FilmLength:    req.FilmLength,
+		Serial:        req.Serial,
+		ShortFilm:     req.ShortFilm,
+		Genres:        req.Genres,
+	}, score, status)
+	switch {
+	case errors.Is(err, films.ErrAlreadyExists):
+		return c.String(http.StatusBadRequest, "Film already exists")
+	case err != nil:
+		return err
+	}
+
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
+}
+
+// providerError responds with the status matching the failure of the film metadata provider
+func providerError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, provider.ErrUnknownProvider):
+		return c.String(http.StatusBadRequest, "unsupported link, add the film manually")
+	case errors.Is(err, kinopoisk.ErrNotFound), errors.Is(err, imdb.ErrNotFound), errors.Is(err, tmdb.ErrNotFound):
+		return c.String(http.StatusNotFound, "film not found on the provider site")
+	case errors.Is(err, kinopoisk.ErrQuotaExceeded):
+		return c.String(http.StatusServiceUnavailable, "kinopoisk quota exceeded, try again later")
+	case errors.Is(err, kinopoisk.ErrUnauthorized):
+		return c.String(http.StatusBadGateway, "kinopoisk rejected the api key")
+	default:
 		return err
 	}
+}
 
-	return c.JSON(http.StatusOK, build(film, userID, false))
+// scoreAndStatus parses the score and the watch status of a new film, at least one of them should be set
+func scoreAndStatus(c echo.Context) (*pfilm.Score, pfilm.Status, string) {
+	scoreStr := c.QueryParam("score")
+	status := pfilm.Status(c.QueryParam("status"))
+	if scoreStr == "" && status == "" {
+		return nil, "", "both score and status params are empty"
+	}
+
+	var score *pfilm.Score
+	if scoreStr != "" {
+		v, err := strconv.Atoi(scoreStr)
+		if err != nil || v < -1 || v > 2 {
+			return nil, "", "score should be in (-1, 0, 1, 2)"
+		}
+		s := pfilm.Score(v)
+		score = &s
+	}
+	if status != "" && !pfilm.ValidStatus(status) {
+		return nil, "", errBadStatus
+	}
+	return score, status, ""
 }
 
 func (h *handler) get(c echo.Context) error {
@@ -116,7 +317,7 @@ func (h *handler) get(c echo.Context) error {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, build(film, userID, true))
+	return c.JSON(http.StatusOK, build(film, userID, true, ratingOf(c)))
 }
 
 func (h *handler) my(c echo.Context) error {
@@ -124,6 +325,15 @@ func (h *handler) my(c echo.Context) error {
 	if err != nil {
 		return c.String(http.StatusUnauthorized, err.Error())
 	}
+	status := pfilm.Status(c.QueryParam("status"))
+	if status != "" && !pfilm.ValidStatus(status) {
+		return c.String(http.StatusBadRequest, errBadStatus)
+	}
+	params, err := parseListParams(c, h.defaultSort)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	userFilms, err := h.film.User(c.Request().Context(), userID)
 	switch {
 	case errors.Is(err, films.ErrNotFound):
@@ -131,23 +341,115 @@ func (h *handler) my(c echo.Context) error {
 	case err != nil:
 		return err
 	}
+	if status != "" {
+		userFilms = userFilms.WithStatus(userID, status)
+	}
 
-	h.sortFilms(userFilms, h.defaultSort)
-	return c.JSON(http.StatusOK, buildAll(userFilms, userID))
+	order, err := h.sortFilms(userFilms, h.defaultSort, ratingOf(c).selected)
+	if err != nil {
+		return err
+	}
+	return h.respondList(c, userFilms, order, userID, params, nil)
 }
 
 func (h *handler) all(c echo.Context) error {
 	userID, _ := h.jwt.ExtractUserID(c)
 	sort := c.QueryParam("sort")
+	params, err := parseListParams(c, sort)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	allFilms, err := h.film.All(c.Request().Context())
 	if err != nil {
 		return err
 	}
 
-	h.sortFilms(allFilms, sort)
+	order, err := h.sortFilms(allFilms, sort, ratingOf(c).selected)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var unread map[string]int
+	if userID != "" {
+		if unread, err = h.film.Unread(c.Request().Context(), userID); err != nil {
+			return err
+		}
+	}
+	return h.respondList(c, allFilms, order, userID, params, unread)
+}
+
+// search filters films by text, genres, years, flags, ratings and scores,
+// text matches are ordered by relevance unless the sort param is given
+func (h *handler) search(c echo.Context) error {
+	userID, _ := h.jwt.ExtractUserID(c)
+	q, err := searchQuery(c, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	sort := c.QueryParam("sort")
+	params, err := parseListParams(c, sort)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	found, err := h.film.Search(c.Request().Context(), q)
+	if err != nil {
+		return err
+	}
+	var order *pfilm.Order
+	if sort != "" || q.Text == "" {
+		if order, err = h.sortFilms(found, sort, ratingOf(c).selected); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	return h.respondList(c, found, order, userID, params, nil)
+}
+
+func searchQuery(c echo.Context, userID string) (*films.Query, error) {
+	q := &films.Query{
+		Text:     c.QueryParam("q"),
+		ScoredBy: c.QueryParam("scored_by"),
+	}
+	if genres := c.QueryParam("genres"); genres != "" {
+		q.Genres = strings.Split(genres, ",")
+	}
+	if c.QueryParam("not_scored") == "true" {
+		q.NotScoredBy = userID
+	}
 
-	return c.JSON(http.StatusOK, buildAll(allFilms, userID))
+	var err error
+	for param, v := range map[string]*int{"year_from": &q.YearFrom, "year_to": &q.YearTo} {
+		if str := c.QueryParam(param); str != "" {
+			if *v, err = strconv.Atoi(str); err != nil {
+				return nil, errors.Errorf("%s should be a year", param)
+			}
+		}
+	}
+	for param, v := range map[string]**bool{"serial": &q.Serial, "short_film": &q.ShortFilm} {
+		if str := c.QueryParam(param); str != "" {
+			b, err := strconv.ParseBool(str)
+			if err != nil {
+				return nil, errors.Errorf("%s should be true or false", param)
+			}
+			*v = &b
+		}
+	}
+	for param, v := range map[string]**float64{
+		"kinopoisk_min": &q.Kinopoisk.Min, "kinopoisk_max": &q.Kinopoisk.Max,
+		"imdb_min": &q.Imdb.Min, "imdb_max": &q.Imdb.Max,
+		"halva_min": &q.Halva.Min, "halva_max": &q.Halva.Max,
+	} {
+		if str := c.QueryParam(param); str != "" {
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				return nil, errors.Errorf("%s should be a number", param)
+			}
+			*v = &f
+		}
+	}
+	return q, nil
 }
 
 func (h *handler) score(c echo.Context) error {
@@ -175,7 +477,7 @@ func (h *handler) score(c echo.Context) error {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, build(film, userID, false))
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
 }
 
 func (h *handler) removeScore(c echo.Context) error {
@@ -199,65 +501,158 @@ func (h *handler) removeScore(c echo.Context) error {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, build(film, "", false))
+	return c.JSON(http.StatusOK, build(film, "", false, ratingOf(c)))
 }
 
-func (h *handler) comment(c echo.Context) error {
+func (h *handler) status(c echo.Context) error {
+	id := c.Param("id")
+	status := pfilm.Status(c.QueryParam("status"))
+	if id == "" || status == "" {
+		return c.String(http.StatusBadRequest, "id or status param is empty")
+	}
+	if !pfilm.ValidStatus(status) {
+		return c.String(http.StatusBadRequest, errBadStatus)
+	}
+
 	userID, err := h.jwt.ExtractUserID(c)
 	if err != nil {
 		return c.String(http.StatusUnauthorized, err.Error())
 	}
 
+	film, err := h.film.SetStatus(c.Request().Context(), userID, id, status)
+	switch {
+	case errors.Is(err, films.ErrNotFound):
+		return c.String(http.StatusNotFound, errFilmNotFound)
+	case err != nil:
+		return err
+	}
+
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
+}
+
+func (h *handler) removeStatus(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
 		return c.String(http.StatusBadRequest, errEmptyID)
 	}
 
-	var req commentRequest
-	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
-		return c.String(http.StatusBadRequest, err.Error())
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
 	}
 
-	film, err := h.film.Comment(c.Request().Context(), userID, id, req.Text)
+	film, err := h.film.RemoveStatus(c.Request().Context(), userID, id)
 	switch {
 	case errors.Is(err, films.ErrNotFound):
 		return c.String(http.StatusNotFound, errFilmNotFound)
+	case errors.Is(err, films.ErrNoStatus):
+		return c.String(http.StatusNotFound, "film is not in your watchlist")
 	case err != nil:
 		return err
 	}
 
-	return c.JSON(http.StatusOK, build(film, userID, true))
-}
-
-func (h *handler) sortFilms(films pfilm.Items, sort string) {
-	switch sort {
-	case SortLexicographic:
-		films.SortLexicographic()
-	case SortRatingKinopoisk:
-		films.SortKinopoisk()
-	case SortRatingImdb:
-		films.SortIMDB()
-	case SortRatingHalva:
-		films.SortHalva()
-	case SortRatingSum:
-		films.SortSum()
-	case SortRatingAverage:
-		films.SortAverage()
-	case SortRatingScoreNumber:
-		films.SortScoreNumber()
-	case SortUpdatedAt:
-		films.SortUpdatedAt()
-	case SortCreatedAt:
-		films.SortCreatedAt()
-	default:
-		if sort == h.defaultSort {
-			return
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
+}
+
+// refresh forces the update of the film metadata and ratings from kinopoisk
+func (h *handler) refresh(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return c.String(http.StatusBadRequest, errEmptyID)
+	}
+
+	userID, _ := h.jwt.ExtractUserID(c)
+	film, err := h.film.Refresh(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, films.ErrNotFound):
+		return c.String(http.StatusNotFound, errFilmNotFound)
+	case err != nil:
+		return providerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, build(film, userID, false, ratingOf(c)))
+}
+
+// plan ranks the films for a movie night of the users, the caller attends if users are not given
+func (h *handler) plan(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	opts, err := planOptions(c, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	candidates, err := h.planner.Candidates(c.Request().Context(), opts)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, buildPlan(nil, candidates, userID, ratingOf(c)))
+}
+
+// pick randomly chooses the film for a movie night, avoiding the suggestions of the previous pick
+func (h *handler) pick(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	opts, err := planOptions(c, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	picked, candidates, err := h.planner.Pick(c.Request().Context(), opts)
+	switch {
+	case errors.Is(err, planner.ErrNoCandidates):
+		return c.String(http.StatusNotFound, "No films match the movie night")
+	case err != nil:
+		return err
+	}
+	return c.JSON(http.StatusOK, buildPlan(picked, candidates, userID, ratingOf(c)))
+}
+
+func planOptions(c echo.Context, userID string) (planner.Options, error) {
+	opts := planner.Options{Users: []string{userID}}
+	if users := c.QueryParam("users"); users != "" {
+		opts.Users = strings.Split(users, ",")
+	}
+
+	var err error
+	if v := c.QueryParam("min_rating"); v != "" {
+		if opts.MinRating, err = strconv.ParseFloat(v, 64); err != nil {
+			return opts, errors.New("min_rating should be a number")
+		}
+	}
+	if v := c.QueryParam("min_length"); v != "" {
+		if opts.MinLength, err = strconv.Atoi(v); err != nil {
+			return opts, errors.New("min_length should be a number of minutes")
+		}
+	}
+	if v := c.QueryParam("max_length"); v != "" {
+		if opts.MaxLength, err = strconv.Atoi(v); err != nil {
+			return opts, errors.New("max_length should be a number of minutes")
 		}
-		h.sortFilms(films, h.defaultSort)
 	}
+	return opts, nil
 }
 
-func build(film *pfilm.Item, userID string, withComments bool) *filmResponse {
+// sortFilms orders the films by the sort expression, the default one is used when it is empty
+func (h *handler) sortFilms(films pfilm.Items, sort string, rating pfilm.RatingStrategy) (*pfilm.Order, error) {
+	if sort == "" {
+		sort = h.defaultSort
+	}
+	order, err := pfilm.ParseOrder(sort, rating)
+	if err != nil {
+		return nil, err
+	}
+	films.SortBy(order.Compare)
+	return order, nil
+}
+
+func build(film *pfilm.Item, userID string, withComments bool, rv *ratingView) *filmResponse {
 	var score *int
 	if v, ok := film.Scores[userID]; userID != "" && ok {
 		vint := int(v)
@@ -265,28 +660,43 @@ func build(film *pfilm.Item, userID string, withComments bool) *filmResponse {
 	}
 
 	var scores map[string]int
+	var statuses map[string]string
 	if userID != "" {
 		scores = make(map[string]int, len(film.Scores))
 		for k, v := range film.Scores {
 			scores[k] = int(v)
 		}
+		statuses = make(map[string]string, len(film.Statuses))
+		for k, v := range film.Statuses {
+			statuses[k] = string(v)
+		}
 	}
 
 	var comments []commentResp
 	if userID != "" && withComments && !film.NoComments {
 		comments = make([]commentResp, 0, len(film.Comments))
+		watched := film.Watched(userID)
 		for i := range film.Comments {
-			comments = append(comments, commentResp{
-				UserID:    film.Comments[i].UserID,
-				Text:      film.Comments[i].Text,
-				CreatedAt: film.Comments[i].CreatedAt,
-			})
+			comments = append(comments, buildComment(&film.Comments[i], userID, watched))
 		}
 		sort.Slice(comments, func(i, j int) bool {
 			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
 		})
 	}
 
+	var (
+		rating   float64
+		strategy string
+		ratings  map[string]float64
+	)
+	if rv != nil {
+		rating, strategy = float64(rv.selected.Rate(film)), rv.selected.Name()
+		ratings = make(map[string]float64, len(rv.all))
+		for _, r := range rv.all {
+			ratings[r.Name()] = float64(r.Rate(film))
+		}
+	}
+
 	return &filmResponse{
 		ID:               film.ID,
 		Title:            film.Title,
@@ -298,13 +708,23 @@ func build(film *pfilm.Item, userID string, withComments bool) *filmResponse {
 		ShortDescription: film.ShortDescription,
 		Duration:         film.Duration,
 		UserScore:        score,
+		UserStatus:       string(film.Statuses[userID]),
+		AddedBy:          film.AddedBy,
 		Scores:           scores,
+		Statuses:         statuses,
 		URL:              film.URL,
+		Provider:         film.Provider,
+		KinopoiskID:      film.KinopoiskID,
+		ImdbID:           film.ImdbID,
+		TmdbID:           film.TmdbID,
 		RatingKinopoisk:  film.RatingKinopoisk,
 		RatingImdb:       film.RatingImdb,
 		RatingHalva:      float64(film.Halva()),
 		RatingSum:        float64(film.Sum()),
 		RatingAverage:    float64(film.Average()),
+		Rating:           rating,
+		RatingStrategy:   strategy,
+		Ratings:          ratings,
 		Year:             film.Year,
 		FilmLength:       film.FilmLength,
 		Serial:           film.Serial,
@@ -316,53 +736,122 @@ func build(film *pfilm.Item, userID string, withComments bool) *filmResponse {
 	}
 }
 
-func buildAll(all pfilm.Items, userID string) allFilmsResponse {
+func buildAll(all pfilm.Items, userID string, rv *ratingView) allFilmsResponse {
 	var resp allFilmsResponse
 	resp.Films = make([]filmResponse, 0, len(all))
 	for i := range all {
-		resp.Films = append(resp.Films, *build(&all[i], userID, false))
+		resp.Films = append(resp.Films, *build(&all[i], userID, false, rv))
+	}
+	return resp
+}
+
+func buildPlan(picked *planner.Candidate, candidates []planner.Candidate, userID string, rv *ratingView) planResponse {
+	buildCandidate := func(c *planner.Candidate) candidateResponse {
+		return candidateResponse{
+			Film:        *build(&c.Film, userID, false, rv),
+			Weight:      c.Weight,
+			SuggestedBy: c.SuggestedBy,
+		}
+	}
+
+	var resp planResponse
+	if picked != nil {
+		p := buildCandidate(picked)
+		resp.Pick = &p
+	}
+	resp.Candidates = make([]candidateResponse, 0, len(candidates))
+	for i := range candidates {
+		resp.Candidates = append(resp.Candidates, buildCandidate(&candidates[i]))
 	}
 	return resp
 }
 
 type filmResponse struct {
-	ID               string         `json:"id"`
-	Title            string         `json:"title"`
-	TitleOriginal    string         `json:"title_original,omitempty"`
-	Poster           string         `json:"cover,omitempty"`
-	Cover            string         `json:"poster,omitempty"`
-	Director         string         `json:"director,omitempty"`
-	Description      string         `json:"description,omitempty"`
-	ShortDescription string         `json:"short_description,omitempty"`
-	Duration         string         `json:"duration,omitempty"`
-	UserScore        *int           `json:"user_score,omitempty"`
-	Scores           map[string]int `json:"scores,omitempty"`
-	URL              string         `json:"kinopoisk,omitempty"`
-	RatingKinopoisk  float64        `json:"rating_kinopoisk"`
-	RatingImdb       float64        `json:"rating_imdb"`
-	RatingHalva      float64        `json:"rating_halva"`
-	RatingSum        float64        `json:"rating_sum"`
-	RatingAverage    float64        `json:"rating_average"`
-	Year             int            `json:"year,omitempty"`
-	FilmLength       int            `json:"film_length,omitempty"`
-	Serial           bool           `json:"serial"`
-	ShortFilm        bool           `json:"short_film"`
-	Genres           []string       `json:"genres,omitempty"`
-	Comments         []commentResp  `json:"comments,omitempty"`
-	UpdatedAt        time.Time      `json:"updated_at,omitempty"`
-	CreatedAt        time.Time      `json:"created_at,omitempty"`
-}
-
-type commentResp struct {
-	UserID    string    `json:"user_id"`
-	Text      string    `json:"text"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               string             `json:"id"`
+	Title            string             `json:"title"`
+	TitleOriginal    string             `json:"title_original,omitempty"`
+	Poster           string             `json:"cover,omitempty"`
+	Cover            string             `json:"poster,omitempty"`
+	Director         string             `json:"director,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	ShortDescription string             `json:"short_description,omitempty"`
+	Duration         string             `json:"duration,omitempty"`
+	UserScore        *int               `json:"user_score,omitempty"`
+	UserStatus       string             `json:"user_status,omitempty"`
+	AddedBy          string             `json:"added_by,omitempty"`
+	Scores           map[string]int     `json:"scores,omitempty"`
+	Statuses         map[string]string  `json:"statuses,omitempty"`
+	URL              string             `json:"kinopoisk,omitempty"`
+	Provider         string             `json:"provider,omitempty"`
+	KinopoiskID      string             `json:"kinopoisk_id,omitempty"`
+	ImdbID           string             `json:"imdb_id,omitempty"`
+	TmdbID           string             `json:"tmdb_id,omitempty"`
+	RatingKinopoisk  float64            `json:"rating_kinopoisk"`
+	RatingImdb       float64            `json:"rating_imdb"`
+	RatingHalva      float64            `json:"rating_halva"`
+	RatingSum        float64            `json:"rating_sum"`
+	RatingAverage    float64            `json:"rating_average"`
+	Rating           float64            `json:"rating"`
+	RatingStrategy   string             `json:"rating_strategy,omitempty"`
+	Ratings          map[string]float64 `json:"ratings,omitempty"`
+	Year             int                `json:"year,omitempty"`
+	FilmLength       int                `json:"film_length,omitempty"`
+	Serial           bool               `json:"serial"`
+	ShortFilm        bool               `json:"short_film"`
+	Genres           []string           `json:"genres,omitempty"`
+	Comments         []commentResp      `json:"comments,omitempty"`
+	UnreadComments   int                `json:"unread_comments,omitempty"`
+	UpdatedAt        time.Time          `json:"updated_at,omitempty"`
+	CreatedAt        time.Time          `json:"created_at,omitempty"`
 }
 
 type allFilmsResponse struct {
-	Films []filmResponse `json:"films"`
+	Films      []filmResponse `json:"films"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type lookupCandidateResponse struct {
+	ID              string   `json:"id"`
+	Provider        string   `json:"provider"`
+	Title           string   `json:"title"`
+	TitleOriginal   string   `json:"title_original,omitempty"`
+	Poster          string   `json:"cover,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	URL             string   `json:"url,omitempty"`
+	RatingKinopoisk float64  `json:"rating_kinopoisk,omitempty"`
+	Year            int      `json:"year,omitempty"`
+	Serial          bool     `json:"serial"`
+	Genres          []string `json:"genres,omitempty"`
+	Added           bool     `json:"added"`
+	FilmID          string   `json:"film_id,omitempty"`
+}
+
+type lookupResponse struct {
+	Candidates []lookupCandidateResponse `json:"candidates"`
+}
+
+type manualRequest struct {
+	Title         string   `json:"title"`
+	TitleOriginal string   `json:"title_original"`
+	Poster        string   `json:"cover"`
+	Director      string   `json:"director"`
+	Description   string   `json:"description"`
+	URL           string   `json:"url"`
+	ImdbID        string   `json:"imdb_id"`
+	Year          int      `json:"year"`
+	FilmLength    int      `json:"film_length"`
+	Serial        bool     `json:"serial"`
+	ShortFilm     bool     `json:"short_film"`
+	Genres        []string `json:"genres"`
+}
+
+type candidateResponse struct {
+	Film        filmResponse `json:"film"`
+	Weight      float64      `json:"weight"`
+	SuggestedBy []string     `json:"suggested_by,omitempty"`
 }
 
-type commentRequest struct {
-	Text string `json:"text"`
+type planResponse struct {
+	Pick       *candidateResponse  `json:"pick,omitempty"`
+	Candidates []candidateResponse `json:"candidates"`
 }
@@ -0,0 +1,221 @@
+package apiv1
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const maxLimit = 500
+
+var (
+	errBadCursor = errors.New("cursor is invalid or belongs to another sort")
+	filmFields   = jsonFields(reflect.TypeOf(filmResponse{}))
+)
+
+// listParams control a page of a films list: limit 0 returns the whole list
+type listParams struct {
+	sort    string
+	limit   int
+	cursor  string
+	compact bool
+	fields  map[string]struct{}
+}
+
+func parseListParams(c echo.Context, sort string) (*listParams, error) {
+	// the order of the "rating" sort key depends on the strategy, so it is a part of the cursor
+	if rv := ratingOf(c); rv != nil {
+		sort += "@" + rv.selected.Name()
+	}
+	p := &listParams{
+		sort:    sort,
+		cursor:  c.QueryParam("cursor"),
+		compact: c.QueryParam("compact") == "true",
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil || v <= 0 || v > maxLimit {
+			return nil, errors.Errorf("limit should be a number from 1 to %d", maxLimit)
+		}
+		p.limit = v
+	}
+	if fields := c.QueryParam("fields"); fields != "" {
+		p.fields = map[string]struct{}{"id": {}}
+		for _, f := range strings.Split(fields, ",") {
+			if _, ok := filmFields[f]; !ok {
+				return nil, errors.Errorf("unknown field %q", f)
+			}
+			p.fields[f] = struct{}{}
+		}
+	}
+	return p, nil
+}
+
+// page cuts the sorted films after the cursor. The cursor keeps the sort key and the ID of the last film,
+// so pages stay put when films are added, re-scored or deleted. Films without an order are paged by the last ID.
+func (p *listParams) page(all pfilm.Items, order *pfilm.Order) (pfilm.Items, string, error) {
+	start := 0
+	if p.cursor != "" {
+		cur, err := decodeCursor(p.cursor)
+		if err != nil || cur.Sort != p.sort || (order != nil) != (cur.Key != nil) {
+			return nil, "", errBadCursor
+		}
+		start = -1
+		for i := range all {
+			if order == nil && all[i].ID == cur.ID {
+				start = i + 1
+				break
+			}
+			if order != nil && order.After(&all[i], cur.Key, cur.ID) {
+				start = i
+				break
+			}
+		}
+		switch {
+		case start < 0 && order != nil:
+			start = len(all)
+		case start < 0:
+			return nil, "", errBadCursor
+		}
+	}
+
+	if p.limit == 0 || start+p.limit >= len(all) {
+		return all[start:], "", nil
+	}
+	page := all[start : start+p.limit]
+	last := &page[len(page)-1]
+	cur := cursor{Sort: p.sort, ID: last.ID}
+	if order != nil {
+		cur.Key = order.Key(last)
+	}
+	return page, encodeCursor(cur), nil
+}
+
+// respondList writes a page of films honouring compact mode, field projection, If-None-Match and unread comment counts if given
+func (h *handler) respondList(c echo.Context, all pfilm.Items, order *pfilm.Order, userID string, p *listParams, unread map[string]int) error {
+	page, next, err := p.page(all, order)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	resp := buildAll(page, userID, ratingOf(c))
+	resp.NextCursor = next
+	for i := range resp.Films {
+		resp.Films[i].UnreadComments = unread[resp.Films[i].ID]
+	}
+	if p.compact {
+		for i := range resp.Films {
+			resp.Films[i].compact()
+		}
+	}
+
+	var body interface{} = resp
+	if p.fields != nil {
+		projected, err := project(resp.Films, p.fields)
+		if err != nil {
+			return err
+		}
+		body = projectedResponse{Films: projected, NextCursor: next}
+	}
+	return jsonWithETag(c, body)
+}
+
+func (f *filmResponse) compact() {
+	f.Description = ""
+	f.ShortDescription = ""
+	f.Scores = nil
+	f.Statuses = nil
+	f.Comments = nil
+}
+
+func project(films []filmResponse, fields map[string]struct{}) ([]map[string]json.RawMessage, error) {
+	res := make([]map[string]json.RawMessage, 0, len(films))
+	for i := range films {
+		raw, err := json.Marshal(films[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal film")
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return nil, errors.Wrap(err, "unmarshal film")
+		}
+		for k := range all {
+			if _, ok := fields[k]; !ok {
+				delete(all, k)
+			}
+		}
+		res = append(res, all)
+	}
+	return res, nil
+}
+
+// jsonWithETag answers 304 when the client already has this exact body
+func jsonWithETag(c echo.Context, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshal response")
+	}
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-cache")
+	c.Response().Header().Set("ETag", etag)
+	if match := c.Request().Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return c.NoContent(http.StatusNotModified)
+			}
+		}
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, body)
+}
+
+func encodeCursor(cur cursor) string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cur cursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+func jsonFields(t reflect.Type) map[string]struct{} {
+	fields := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = struct{}{}
+		}
+	}
+	return fields
+}
+
+type projectedResponse struct {
+	Films      []map[string]json.RawMessage `json:"films"`
+	NextCursor string                       `json:"next_cursor,omitempty"`
+}
+
+type cursor struct {
+	Sort string        `json:"s"`
+	Key  []pfilm.Value `json:"k,omitempty"`
+	ID   string        `json:"id"`
+}
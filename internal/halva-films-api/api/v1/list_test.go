@@ -0,0 +1,118 @@
+package apiv1
+
+import (
+	"strings"
+	"testing"
+
+	pfilm "github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+func TestListParams_Page(t *testing.T) {
+	tests := []struct {
+		name string
+		// change is applied to the films after the first page
+		change func(all pfilm.Items) pfilm.Items
+		want   string
+	}{
+		{
+			name:   "added",
+			change: func(all pfilm.Items) pfilm.Items { return append(all, pfilm.Item{ID: "new", RatingKinopoisk: 9}) },
+			want:   "abcde",
+		},
+		{
+			name: "cursor film re-scored",
+			change: func(all pfilm.Items) pfilm.Items {
+				all[1].RatingKinopoisk = 10
+				return all
+			},
+			want: "abcde",
+		},
+		{
+			name:   "cursor film deleted",
+			change: func(all pfilm.Items) pfilm.Items { return append(all[:1:1], all[2:]...) },
+			want:   "abcde",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := pfilm.Items{
+				{ID: "a", RatingKinopoisk: 5},
+				{ID: "b", RatingKinopoisk: 4},
+				{ID: "c", RatingKinopoisk: 3},
+				{ID: "d", RatingKinopoisk: 2},
+				{ID: "e", RatingKinopoisk: 1},
+			}
+			order, err := pfilm.ParseOrder("kinopoisk", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &listParams{sort: "kinopoisk", limit: 2}
+
+			var got []string
+			for i := 0; i < len(all); i++ {
+				all.SortBy(order.Compare)
+				page, next, err := p.page(all, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for j := range page {
+					got = append(got, page[j].ID)
+				}
+				if next == "" {
+					break
+				}
+				p.cursor = next
+				if i == 0 {
+					all = tt.change(all)
+				}
+			}
+			if strings.Join(got, "") != tt.want {
+				t.Errorf("expected: %s, got: %s", tt.want, strings.Join(got, ""))
+			}
+		})
+	}
+}
+
+func TestListParams_PageByID(t *testing.T) {
+	all := pfilm.Items{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"}}
+	p := &listParams{limit: 2}
+
+	var got []string
+	for i := 0; i < len(all); i++ {
+		page, next, err := p.page(all, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range page {
+			got = append(got, page[j].ID)
+		}
+		if next == "" {
+			break
+		}
+		p.cursor = next
+		// a film added in the middle must not shift the following pages
+		if i == 0 {
+			all = append(pfilm.Items{{ID: "new"}}, all...)
+		}
+	}
+	if want := "abcde"; strings.Join(got, "") != want {
+		t.Errorf("expected: %s, got: %s", want, strings.Join(got, ""))
+	}
+}
+
+func TestListParams_PageBadCursor(t *testing.T) {
+	order, err := pfilm.ParseOrder("lexicographic", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := pfilm.Items{{ID: "a"}, {ID: "b"}}
+
+	p := &listParams{sort: "lexicographic", limit: 1, cursor: encodeCursor(cursor{Sort: "halva", ID: "a"})}
+	if _, _, err := p.page(all, order); err != errBadCursor {
+		t.Errorf("expected errBadCursor for a cursor of another sort, got: %v", err)
+	}
+	p.cursor = "not a cursor"
+	if _, _, err := p.page(all, order); err != errBadCursor {
+		t.Errorf("expected errBadCursor for a broken cursor, got: %v", err)
+	}
+}
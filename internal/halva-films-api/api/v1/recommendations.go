@@ -0,0 +1,65 @@
+package apiv1
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/recommender"
+)
+
+const defaultRecommendations = 10
+
+type recommenderService interface {
+	Recommend(ctx context.Context, userID string, limit int) ([]recommender.Recommendation, error)
+}
+
+// recommendations suggests films the user has not scored yet with an explanation of each suggestion
+func (h *handler) recommendations(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	limit := defaultRecommendations
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLimit {
+			return c.String(http.StatusBadRequest, "limit should be a positive number of films")
+		}
+		limit = n
+	}
+
+	recommendations, err := h.recommender.Recommend(c.Request().Context(), userID, limit)
+	switch {
+	case errors.Is(err, recommender.ErrNoScores):
+		return c.String(http.StatusUnprocessableEntity, "score some films first")
+	case err != nil:
+		return err
+	}
+
+	rv := ratingOf(c)
+	resp := recommendationsResponse{Items: make([]recommendationResponse, 0, len(recommendations))}
+	for i := range recommendations {
+		r := &recommendations[i]
+		resp.Items = append(resp.Items, recommendationResponse{
+			Film:        *build(&r.Film, userID, false, rv),
+			Score:       r.Score,
+			Explanation: r.Explanation,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+type recommendationResponse struct {
+	Film        filmResponse `json:"film"`
+	Score       float64      `json:"score"`
+	Explanation string       `json:"explanation"`
+}
+
+type recommendationsResponse struct {
+	Items []recommendationResponse `json:"items"`
+}
@@ -0,0 +1,210 @@
+package apiv1
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+
+	films "github.com/HalvaPovidlo/halva-services/internal/halva-films-api/film"
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/session"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+)
+
+const errSessionNotFound = "session not found"
+
+type sessionService interface {
+	Create(ctx context.Context, userID, filmURL string, startsAt time.Time, attendees []string) (*session.Item, error)
+	Get(id string) (*session.Item, error)
+	All() []session.Item
+	RSVP(ctx context.Context, id, userID string, rsvp session.RSVP) (*session.Item, error)
+	Delete(ctx context.Context, id, userID string, force bool) error
+	Unscored(ctx context.Context, items []session.Item) (map[string][]string, error)
+	Reminders(ctx context.Context, userID string) ([]session.Item, error)
+}
+
+func (h *handler) createSession(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	filmID := c.QueryParam("film")
+	if filmID == "" {
+		return c.String(http.StatusBadRequest, "film param is empty")
+	}
+	startsAt, err := time.Parse(time.RFC3339, c.QueryParam("at"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "at should be a RFC 3339 datetime")
+	}
+	var attendees []string
+	if users := c.QueryParam("users"); users != "" {
+		attendees = strings.Split(users, ",")
+	}
+
+	ctx := c.Request().Context()
+	item, err := h.session.Create(ctx, userID, filmID, startsAt, attendees)
+	switch {
+	case errors.Is(err, films.ErrNotFound):
+		return c.String(http.StatusNotFound, errFilmNotFound)
+	case err != nil:
+		return err
+	}
+	return h.sessionJSON(c, item)
+}
+
+func (h *handler) sessions(c echo.Context) error {
+	ctx := c.Request().Context()
+	all := h.session.All()
+	if c.QueryParam("upcoming") == "true" {
+		now := time.Now()
+		upcoming := make([]session.Item, 0, len(all))
+		for i := range all {
+			if all[i].StartsAt.After(now) {
+				upcoming = append(upcoming, all[i])
+			}
+		}
+		all = upcoming
+	}
+
+	resp, err := h.buildSessions(ctx, all)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) getSession(c echo.Context) error {
+	item, err := h.session.Get(c.Param("id"))
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		return c.String(http.StatusNotFound, errSessionNotFound)
+	case err != nil:
+		return err
+	}
+	return h.sessionJSON(c, item)
+}
+
+func (h *handler) rsvp(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	rsvp := session.RSVP(c.QueryParam("rsvp"))
+	if !session.ValidRSVP(rsvp) {
+		return c.String(http.StatusBadRequest, "rsvp should be in (going, maybe, declined)")
+	}
+
+	item, err := h.session.RSVP(c.Request().Context(), c.Param("id"), userID, rsvp)
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		return c.String(http.StatusNotFound, errSessionNotFound)
+	case err != nil:
+		return err
+	}
+	return h.sessionJSON(c, item)
+}
+
+// deleteSession cancels the session, admins can cancel sessions of other users
+func (h *handler) deleteSession(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+	role, _ := h.jwt.ExtractRole(c)
+
+	err = h.session.Delete(c.Request().Context(), c.Param("id"), userID, role == user.RoleAdmin)
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		return c.String(http.StatusNotFound, errSessionNotFound)
+	case errors.Is(err, session.ErrForbidden):
+		return c.String(http.StatusForbidden, "Only the creator of the session can cancel it")
+	case err != nil:
+		return err
+	}
+	return c.String(http.StatusOK, "Session was cancelled")
+}
+
+// reminders lists finished sessions the caller went to but has not scored the film of
+func (h *handler) reminders(c echo.Context) error {
+	userID, err := h.jwt.ExtractUserID(c)
+	if err != nil {
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	reminders, err := h.session.Reminders(ctx, userID)
+	if err != nil {
+		return err
+	}
+	resp, err := h.buildSessions(ctx, reminders)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) sessionJSON(c echo.Context, item *session.Item) error {
+	resp, err := h.buildSessions(c.Request().Context(), []session.Item{*item})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, resp.Sessions[0])
+}
+
+// buildSessions looks up the films once for all the sessions
+func (h *handler) buildSessions(ctx context.Context, items []session.Item) (sessionsResponse, error) {
+	resp := sessionsResponse{Sessions: make([]sessionResponse, 0, len(items))}
+	unscored, err := h.session.Unscored(ctx, items)
+	if err != nil {
+		return resp, err
+	}
+	for i := range items {
+		resp.Sessions = append(resp.Sessions, *buildSession(&items[i], unscored[items[i].ID]))
+	}
+	return resp, nil
+}
+
+func buildSession(item *session.Item, unscored []string) *sessionResponse {
+	attendees := make([]attendeeResponse, 0, len(item.Attendees))
+	for userID, rsvp := range item.Attendees {
+		attendees = append(attendees, attendeeResponse{UserID: userID, RSVP: string(rsvp)})
+	}
+	sort.Slice(attendees, func(i, j int) bool {
+		return attendees[i].UserID < attendees[j].UserID
+	})
+
+	return &sessionResponse{
+		ID:        item.ID,
+		FilmID:    item.FilmID,
+		CreatedBy: item.CreatedBy,
+		StartsAt:  item.StartsAt,
+		Attendees: attendees,
+		Unscored:  unscored,
+		CreatedAt: item.CreatedAt,
+	}
+}
+
+type attendeeResponse struct {
+	UserID string `json:"user_id"`
+	RSVP   string `json:"rsvp"`
+}
+
+type sessionResponse struct {
+	ID        string             `json:"id"`
+	FilmID    string             `json:"film_id"`
+	CreatedBy string             `json:"created_by"`
+	StartsAt  time.Time          `json:"starts_at"`
+	Attendees []attendeeResponse `json:"attendees"`
+	Unscored  []string           `json:"unscored,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
@@ -0,0 +1,116 @@
+package apiv1
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/stats"
+)
+
+const defaultControversial = 10
+
+type statsService interface {
+	Report(ctx context.Context, controversial int) (*stats.Report, error)
+}
+
+// statistics returns score distributions and favourites of every user, compatibility of every pair and controversial films
+func (h *handler) statistics(c echo.Context) error {
+	userID, _ := h.jwt.ExtractUserID(c)
+
+	controversial := defaultControversial
+	if v := c.QueryParam("controversial"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLimit {
+			return c.String(http.StatusBadRequest, "controversial should be a positive number of films")
+		}
+		controversial = n
+	}
+
+	report, err := h.stats.Report(c.Request().Context(), controversial)
+	if err != nil {
+		return err
+	}
+
+	resp := statsResponse{
+		Users:         make([]userStatsResponse, 0, len(report.Users)),
+		Pairs:         make([]pairResponse, 0, len(report.Pairs)),
+		Controversial: make([]controversialResponse, 0, len(report.Controversial)),
+	}
+	for i := range report.Users {
+		u := &report.Users[i]
+		distribution := make(map[string]int, len(u.Distribution))
+		for score, n := range u.Distribution {
+			distribution[strconv.Itoa(int(score))] = n
+		}
+		resp.Users = append(resp.Users, userStatsResponse{
+			UserID:       u.UserID,
+			Scored:       u.Scored,
+			Average:      u.Average,
+			Distribution: distribution,
+			Genres:       buildFavourites(u.Genres),
+			Directors:    buildFavourites(u.Directors),
+			Decades:      buildFavourites(u.Decades),
+		})
+	}
+	for _, p := range report.Pairs {
+		resp.Pairs = append(resp.Pairs, pairResponse{
+			Users:       [2]string{p.UserA, p.UserB},
+			Common:      p.Common,
+			Agreement:   p.Agreement,
+			Correlation: p.Correlation,
+		})
+	}
+	for i := range report.Controversial {
+		f := &report.Controversial[i]
+		resp.Controversial = append(resp.Controversial, controversialResponse{
+			Film:     *build(&f.Film, userID, false, ratingOf(c)),
+			Variance: f.Variance,
+		})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func buildFavourites(favourites []stats.Favourite) []favouriteResponse {
+	res := make([]favouriteResponse, 0, len(favourites))
+	for _, f := range favourites {
+		res = append(res, favouriteResponse{Name: f.Name, Films: f.Films, Average: f.Average})
+	}
+	return res
+}
+
+type favouriteResponse struct {
+	Name    string  `json:"name"`
+	Films   int     `json:"films"`
+	Average float64 `json:"average"`
+}
+
+type userStatsResponse struct {
+	UserID       string              `json:"user_id"`
+	Scored       int                 `json:"scored"`
+	Average      float64             `json:"average"`
+	Distribution map[string]int      `json:"distribution"`
+	Genres       []favouriteResponse `json:"genres"`
+	Directors    []favouriteResponse `json:"directors"`
+	Decades      []favouriteResponse `json:"decades"`
+}
+
+type pairResponse struct {
+	Users       [2]string `json:"users"`
+	Common      int       `json:"common"`
+	Agreement   float64   `json:"agreement"`
+	Correlation float64   `json:"correlation"`
+}
+
+type controversialResponse struct {
+	Film     filmResponse `json:"film"`
+	Variance float64      `json:"variance"`
+}
+
+type statsResponse struct {
+	Users         []userStatsResponse     `json:"users"`
+	Pairs         []pairResponse          `json:"pairs"`
+	Controversial []controversialResponse `json:"controversial"`
+}
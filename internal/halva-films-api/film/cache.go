@@ -12,26 +12,36 @@ import (
 type userCache map[string]map[string]struct{}
 
 type cache struct {
-	film *pcache.Cache // films.Item
+	film  *pcache.Cache // films.Item
+	index *index
 
-	mx   *sync.RWMutex
-	user userCache // userID -> filmsID -> struct
+	mx       *sync.RWMutex
+	user     userCache                       // userID -> filmsID -> struct
+	lastRead map[string]map[string]time.Time // userID -> filmID -> time
 }
 
 func NewCache(defaultExpiration, cleanupInterval time.Duration) *cache {
 	return &cache{
-		film: pcache.New(defaultExpiration, cleanupInterval),
-		mx:   &sync.RWMutex{},
-		user: make(userCache, 10),
+		film:     pcache.New(defaultExpiration, cleanupInterval),
+		index:    newIndex(),
+		mx:       &sync.RWMutex{},
+		user:     make(userCache, 10),
+		lastRead: make(map[string]map[string]time.Time, 10),
 	}
 }
 
 func (c *cache) Set(item *film.Item) {
 	if item != nil {
 		c.film.SetDefault(item.ID, *item)
+		c.index.add(item)
 	}
 }
 
+// Match returns IDs of films whose texts match the query, the most relevant first
+func (c *cache) Match(query string) []string {
+	return c.index.match(query)
+}
+
 func (c *cache) Get(id string) (*film.Item, bool) {
 	v, ok := c.film.Get(id)
 	if !ok {
@@ -115,3 +125,34 @@ func (c *cache) UserRemove(userID string, filmID string) {
 
 	delete(films, filmID)
 }
+
+func (c *cache) LastRead(userID string) (map[string]time.Time, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	reads, ok := c.lastRead[userID]
+	if !ok {
+		return nil, false
+	}
+	res := make(map[string]time.Time, len(reads))
+	for k, v := range reads {
+		res[k] = v
+	}
+	return res, true
+}
+
+func (c *cache) SetLastRead(userID string, reads map[string]time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.lastRead[userID] = reads
+}
+
+func (c *cache) Read(userID, filmID string, at time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if reads, ok := c.lastRead[userID]; ok {
+		reads[filmID] = at
+	}
+}
@@ -0,0 +1,239 @@
+package film
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	weightTitle       = 3
+	weightDirector    = 2
+	weightDescription = 1
+
+	matchExact  = 3
+	matchPrefix = 2
+	matchFuzzy  = 1
+)
+
+// index is an inverted index over the text fields of films.
+// Words are normalized (lower case, ё as е) and split into trigrams, so typos are found by edit distance among similar words.
+type index struct {
+	mx       sync.RWMutex
+	words    map[string]map[string]int      // word -> filmID -> best field weight
+	trigrams map[string]map[string]struct{} // trigram -> words
+	films    map[string][]string            // filmID -> words
+}
+
+func newIndex() *index {
+	return &index{
+		words:    make(map[string]map[string]int),
+		trigrams: make(map[string]map[string]struct{}),
+		films:    make(map[string][]string),
+	}
+}
+
+func (x *index) add(item *film.Item) {
+	fields := map[string]int{}
+	for _, field := range []struct {
+		text   string
+		weight int
+	}{
+		{item.Title, weightTitle},
+		{item.TitleOriginal, weightTitle},
+		{item.Director, weightDirector},
+		{item.ShortDescription, weightDescription},
+		{item.Description, weightDescription},
+	} {
+		for _, w := range tokenize(field.text) {
+			if fields[w] < field.weight {
+				fields[w] = field.weight
+			}
+		}
+	}
+
+	x.mx.Lock()
+	defer x.mx.Unlock()
+	x.remove(item.ID)
+
+	words := make([]string, 0, len(fields))
+	for w, weight := range fields {
+		films, ok := x.words[w]
+		if !ok {
+			films = make(map[string]int)
+			x.words[w] = films
+			for _, t := range trigrams(w) {
+				if x.trigrams[t] == nil {
+					x.trigrams[t] = make(map[string]struct{})
+				}
+				x.trigrams[t][w] = struct{}{}
+			}
+		}
+		films[item.ID] = weight
+		words = append(words, w)
+	}
+	x.films[item.ID] = words
+}
+
+// remove should be called under the write lock
+func (x *index) remove(filmID string) {
+	for _, w := range x.films[filmID] {
+		films := x.words[w]
+		delete(films, filmID)
+		if len(films) != 0 {
+			continue
+		}
+		delete(x.words, w)
+		for _, t := range trigrams(w) {
+			delete(x.trigrams[t], w)
+			if len(x.trigrams[t]) == 0 {
+				delete(x.trigrams, t)
+			}
+		}
+	}
+	delete(x.films, filmID)
+}
+
+// match returns IDs of films containing every word of the text, the most relevant first
+func (x *index) match(text string) []string {
+	query := tokenize(text)
+	if len(query) == 0 {
+		return nil
+	}
+
+	x.mx.RLock()
+	defer x.mx.RUnlock()
+
+	var ranks map[string]int
+	for _, q := range query {
+		found := make(map[string]int)
+		for w, quality := range x.similar(q) {
+			for filmID, weight := range x.words[w] {
+				if rank := quality * weight; found[filmID] < rank {
+					found[filmID] = rank
+				}
+			}
+		}
+		if ranks == nil {
+			ranks = found
+			continue
+		}
+		for filmID := range ranks {
+			if rank, ok := found[filmID]; ok {
+				ranks[filmID] += rank
+			} else {
+				delete(ranks, filmID)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(ranks))
+	for filmID := range ranks {
+		ids = append(ids, filmID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ranks[ids[i]] == ranks[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return ranks[ids[i]] > ranks[ids[j]]
+	})
+	return ids
+}
+
+// similar finds indexed words equal to q, starting with q or within the allowed number of typos
+func (x *index) similar(q string) map[string]int {
+	res := make(map[string]int)
+	if _, ok := x.words[q]; ok {
+		res[q] = matchExact
+	}
+
+	maxTypos := allowedTypos(q)
+	for _, t := range trigrams(q) {
+		for w := range x.trigrams[t] {
+			if _, ok := res[w]; ok {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(w, q):
+				res[w] = matchPrefix
+			case maxTypos > 0 && distance(q, w) <= maxTypos:
+				res[w] = matchFuzzy
+			}
+		}
+	}
+	return res
+}
+
+func allowedTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalize lowers the text and folds ё into е, й is a separate letter and is kept
+func normalize(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r = unicode.ToLower(r); r == 'ё' {
+			return 'е'
+		}
+		return r
+	}, text)
+}
+
+func trigrams(word string) []string {
+	runes := []rune("^" + word + "$")
+	res := make([]string, 0, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		res = append(res, string(runes[i:i+3]))
+	}
+	if len(res) == 0 {
+		res = append(res, string(runes))
+	}
+	return res
+}
+
+// distance is the Levenshtein distance between the words
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
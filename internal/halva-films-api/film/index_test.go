@@ -0,0 +1,46 @@
+package film
+
+import (
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+func TestIndex_Match(t *testing.T) {
+	x := newIndex()
+	x.add(&film.Item{ID: "1", Title: "Ёлки", Director: "Тимур Бекмамбетов"})
+	x.add(&film.Item{ID: "2", Title: "Матрица", TitleOriginal: "The Matrix", Director: "Лана Вачовски"})
+	x.add(&film.Item{ID: "3", Title: "Бойцовский клуб", Description: "Матрица офисной жизни"})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "елки", want: []string{"1"}},
+		{query: "МАТРИЦА", want: []string{"2", "3"}},
+		{query: "матрца", want: []string{"2", "3"}},
+		{query: "matrx", want: []string{"2"}},
+		{query: "бекмамб", want: []string{"1"}},
+		{query: "бойцовский клуб", want: []string{"3"}},
+		{query: "ЁЛКИ", want: []string{"1"}},
+		{query: "клуб вачовски", want: []string{}},
+	}
+	for _, tt := range tests {
+		got := x.match(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got: %v", tt.query, tt.want, got)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got: %v", tt.query, tt.want, got)
+				break
+			}
+		}
+	}
+
+	x.add(&film.Item{ID: "2", Title: "Начало"})
+	if got := x.match("матрица"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("expected only the description match after the update, got: %v", got)
+	}
+}
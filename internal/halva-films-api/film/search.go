@@ -0,0 +1,99 @@
+package film
+
+import (
+	"context"
+	"strings"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+// Range is an inclusive range of ratings, nil bounds are open
+type Range struct {
+	Min *float64
+	Max *float64
+}
+
+func (r Range) contains(v float64) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+// Query filters films, zero values match everything
+type Query struct {
+	Text        string
+	Genres      []string
+	YearFrom    int
+	YearTo      int
+	Serial      *bool
+	ShortFilm   *bool
+	Kinopoisk   Range
+	Imdb        Range
+	Halva       Range
+	ScoredBy    string
+	NotScoredBy string
+}
+
+// Search returns films matching the query, ordered by relevance when the query has text
+func (s *service) Search(ctx context.Context, q *Query) (film.Items, error) {
+	all, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := all
+	if strings.TrimSpace(q.Text) != "" {
+		ids := s.cache.Match(q.Text)
+		candidates = make(film.Items, 0, len(ids))
+		for i := range ids {
+			if f, ok := s.cache.Get(ids[i]); ok {
+				candidates = append(candidates, *f)
+			}
+		}
+	}
+
+	res := make(film.Items, 0, len(candidates))
+	for i := range candidates {
+		if q.matches(&candidates[i]) {
+			res = append(res, candidates[i])
+		}
+	}
+	return res, nil
+}
+
+func (q *Query) matches(f *film.Item) bool {
+	switch {
+	case q.YearFrom != 0 && f.Year < q.YearFrom,
+		q.YearTo != 0 && f.Year > q.YearTo,
+		q.Serial != nil && f.Serial != *q.Serial,
+		q.ShortFilm != nil && f.ShortFilm != *q.ShortFilm,
+		!q.Kinopoisk.contains(f.RatingKinopoisk),
+		!q.Imdb.contains(f.RatingImdb),
+		!q.Halva.contains(float64(f.Halva())):
+		return false
+	}
+	if q.ScoredBy != "" {
+		if _, ok := f.Scores[q.ScoredBy]; !ok {
+			return false
+		}
+	}
+	if q.NotScoredBy != "" {
+		if _, ok := f.Scores[q.NotScoredBy]; ok {
+			return false
+		}
+	}
+	for _, genre := range q.Genres {
+		if !hasGenre(f, genre) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasGenre(f *film.Item, genre string) bool {
+	genre = normalize(strings.TrimSpace(genre))
+	for i := range f.Genres {
+		if normalize(f.Genres[i]) == genre {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,9 @@ import (
 var (
 	ErrAlreadyExists = errors.New("film already exists")
 	ErrNoScore       = errors.New("film has no score from the user")
+	ErrNoStatus      = errors.New("film has no watch status from the user")
+	ErrNoComment     = errors.New("comment not found")
+	ErrForbidden     = errors.New("only the author can change the comment")
 	defaultDate      = time.Date(1999, time.August, 31, 6, 0, 0, 0, time.Local)
 )
 
@@ -21,69 +24,153 @@ type cacheService interface {
 	Get(id string) (*film.Item, bool)
 	SetAll(items film.Items)
 	All() film.Items
+	Match(query string) []string
 	User(userID string) ([]string, bool)
 	SetUser(userID string, filmsID []string)
 	UserAdd(userID string, filmID string)
 	UserRemove(userID string, filmID string)
+	LastRead(userID string) (map[string]time.Time, bool)
+	SetLastRead(userID string, reads map[string]time.Time)
+	Read(userID, filmID string, at time.Time)
 }
 
 type storageService interface {
 	Set(ctx context.Context, userID string, item *film.Item) error
+	Refresh(ctx context.Context, item *film.Item) error
 	All(ctx context.Context) (film.Items, error)
 	User(ctx context.Context, userID string) ([]string, error)
 	Comments(ctx context.Context, filmID string) ([]film.Comment, error)
 	AddComment(ctx context.Context, filmID string, comment *film.Comment) error
+	SetComment(ctx context.Context, filmID string, comment *film.Comment) error
+	DeleteComment(ctx context.Context, filmID, commentID string) error
+	BackfillCommentTimes(ctx context.Context, films film.Items) error
+	LastRead(ctx context.Context, userID string) (map[string]time.Time, error)
+	SetLastRead(ctx context.Context, userID, filmID string, at time.Time) error
 }
 
-type kinopoisk interface {
+type provider interface {
 	GetFilm(ctx context.Context, url string) (*film.Item, error)
-	ExtractID(uri string) string
+	Refresh(ctx context.Context, f *film.Item) (*film.Item, error)
+	Manual(f *film.Item) (*film.Item, error)
+	Search(ctx context.Context, query string) (film.Items, error)
+	ExtractID(url string) string
+}
+
+// Candidate is a film found on a provider site, AddedID is the ID of the same film if it has already been added
+type Candidate struct {
+	Film    film.Item
+	AddedID string
 }
 
 type service struct {
-	cache     cacheService
-	storage   storageService
-	kinopoisk kinopoisk
+	cache    cacheService
+	storage  storageService
+	provider provider
 }
 
-func New(kinopoisk kinopoisk, cache cacheService, storage storageService) *service {
+func New(provider provider, cache cacheService, storage storageService) *service {
 	return &service{
-		cache:     cache,
-		storage:   storage,
-		kinopoisk: kinopoisk,
+		cache:    cache,
+		storage:  storage,
+		provider: provider,
 	}
 }
 
 func (s *service) FillCache(ctx context.Context) error {
 	films, err := s.All(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.BackfillCommentTimes(ctx, films); err != nil {
+		return errors.Wrap(err, "backfill comment times")
+	}
+	s.cache.SetAll(films)
+
 	users := make(map[string][]string)
 	for i := range films {
 		for userID, _ := range films[i].Scores {
 			users[userID] = append(users[userID], films[i].ID)
 		}
+		for userID := range films[i].Statuses {
+			users[userID] = append(users[userID], films[i].ID)
+		}
 	}
 
 	for userID, films := range users {
 		s.cache.SetUser(userID, films)
 	}
 
-	return err
+	return nil
 }
 
-func (s *service) New(ctx context.Context, userID, url string, score film.Score) (*film.Item, error) {
-	id := s.kinopoisk.ExtractID(url)
+// New adds the film with the score or the watch status of the user, at least one of them should be set
+func (s *service) New(ctx context.Context, userID, url string, score *film.Score, status film.Status) (*film.Item, error) {
+	id := s.provider.ExtractID(url)
 	if _, ok := s.cache.Get(id); ok {
 		return nil, ErrAlreadyExists
 	}
 
-	f, err := s.kinopoisk.GetFilm(ctx, id)
+	f, err := s.provider.GetFilm(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "get film from provider")
+	}
+	return s.add(ctx, userID, f, score, status)
+}
+
+// Lookup finds films on the provider sites to add them by ID instead of a link
+func (s *service) Lookup(ctx context.Context, query string) ([]Candidate, error) {
+	found, err := s.provider.Search(ctx, query)
 	if err != nil {
-		return nil, errors.New("get film from kinopoisk")
+		return nil, errors.Wrap(err, "search films on providers")
+	}
+
+	cached := s.cache.All()
+	res := make([]Candidate, 0, len(found))
+	for i := range found {
+		c := Candidate{Film: found[i]}
+		if f, ok := s.cache.Get(found[i].ID); ok {
+			c.AddedID = f.ID
+		}
+		for j := 0; j < len(cached) && c.AddedID == ""; j++ {
+			if cached[j].SameAs(&found[i]) {
+				c.AddedID = cached[j].ID
+			}
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// NewManual adds the film no provider knows, the user fills all the metadata
+func (s *service) NewManual(ctx context.Context, userID string, item *film.Item, score *film.Score, status film.Status) (*film.Item, error) {
+	f, err := s.provider.Manual(item)
+	if err != nil {
+		return nil, err
+	}
+	return s.add(ctx, userID, f, score, status)
+}
+
+func (s *service) add(ctx context.Context, userID string, f *film.Item, score *film.Score, status film.Status) (*film.Item, error) {
+	if _, ok := s.cache.Get(f.ID); ok {
+		return nil, ErrAlreadyExists
+	}
+	cached := s.cache.All()
+	for i := range cached {
+		if cached[i].SameAs(f) {
+			return nil, ErrAlreadyExists
+		}
 	}
 
 	f.CreatedAt = time.Now()
+	f.RefreshedAt = f.CreatedAt
+	f.AddedBy = userID
 	f.Scores = make(map[string]film.Score, 10)
-	f.Scores[userID] = score
+	if score != nil {
+		f.Scores[userID] = *score
+	}
+	if status != "" {
+		f.Statuses = map[string]film.Status{userID: status}
+	}
 
 	if err := s.storage.Set(ctx, userID, f); err != nil {
 		return nil, errors.Wrap(err, "insert film in storage")
@@ -98,8 +185,13 @@ func (s *service) Get(ctx context.Context, url string) (*film.Item, error) {
 	return s.get(ctx, url, true)
 }
 
+// Find returns the film without loading its comments
+func (s *service) Find(ctx context.Context, url string) (*film.Item, error) {
+	return s.get(ctx, url, false)
+}
+
 func (s *service) get(ctx context.Context, url string, withComments bool) (*film.Item, error) {
-	id := s.kinopoisk.ExtractID(url)
+	id := s.provider.ExtractID(url)
 	f, ok := s.cache.Get(id)
 	if !ok {
 		return nil, ErrNotFound
@@ -136,6 +228,11 @@ func (s *service) All(ctx context.Context) (film.Items, error) {
 		if films[i].UpdatedAt.IsZero() {
 			films[i].UpdatedAt = defaultDate
 		}
+		// films were added only from kinopoisk before other providers appeared
+		if films[i].Provider == "" {
+			films[i].Provider = film.ProviderKinopoisk
+			films[i].KinopoiskID = films[i].ID
+		}
 	}
 
 	s.cache.SetAll(films)
@@ -143,7 +240,7 @@ func (s *service) All(ctx context.Context) (film.Items, error) {
 }
 
 func (s *service) Score(ctx context.Context, userID, url string, score film.Score) (*film.Item, error) {
-	id := s.kinopoisk.ExtractID(url)
+	id := s.provider.ExtractID(url)
 	cached, ok := s.cache.Get(id)
 	if !ok {
 		return nil, ErrNotFound
@@ -153,6 +250,9 @@ func (s *service) Score(ctx context.Context, userID, url string, score film.Scor
 		cached.Scores = make(map[string]film.Score, 10)
 	}
 	cached.Scores[userID] = score
+	if status := cached.Statuses[userID]; status == film.StatusWant || status == film.StatusWatching {
+		cached.Statuses[userID] = film.StatusWatched
+	}
 
 	if err := s.storage.Set(ctx, userID, cached); err != nil {
 		return nil, errors.Wrap(err, "insert film to storage")
@@ -164,7 +264,7 @@ func (s *service) Score(ctx context.Context, userID, url string, score film.Scor
 }
 
 func (s *service) RemoveScore(ctx context.Context, userID, url string) (*film.Item, error) {
-	id := s.kinopoisk.ExtractID(url)
+	id := s.provider.ExtractID(url)
 	cached, ok := s.cache.Get(id)
 	if !ok {
 		return nil, ErrNotFound
@@ -180,36 +280,280 @@ func (s *service) RemoveScore(ctx context.Context, userID, url string) (*film.It
 		return nil, errors.Wrap(err, "insert film to storage")
 	}
 	s.cache.Set(cached)
-	s.cache.UserRemove(userID, cached.ID)
+	if _, ok := cached.Statuses[userID]; !ok {
+		s.cache.UserRemove(userID, cached.ID)
+	}
+	return cached, nil
+}
+
+// SetStatus puts the film in the user's watchlist with the status
+func (s *service) SetStatus(ctx context.Context, userID, url string, status film.Status) (*film.Item, error) {
+	id := s.provider.ExtractID(url)
+	cached, ok := s.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if len(cached.Statuses) == 0 {
+		cached.Statuses = make(map[string]film.Status, 10)
+	}
+	cached.Statuses[userID] = status
+
+	if err := s.storage.Set(ctx, userID, cached); err != nil {
+		return nil, errors.Wrap(err, "insert film to storage")
+	}
+
+	s.cache.Set(cached)
+	s.cache.UserAdd(userID, cached.ID)
 	return cached, nil
 }
 
-func (s *service) Comment(ctx context.Context, userID, url, text string) (*film.Item, error) {
+func (s *service) RemoveStatus(ctx context.Context, userID, url string) (*film.Item, error) {
+	id := s.provider.ExtractID(url)
+	cached, ok := s.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if _, ok := cached.Statuses[userID]; !ok {
+		return nil, ErrNoStatus
+	}
+
+	delete(cached.Statuses, userID)
+
+	if err := s.storage.Set(ctx, userID, cached); err != nil {
+		return nil, errors.Wrap(err, "insert film to storage")
+	}
+	s.cache.Set(cached)
+	if _, ok := cached.Scores[userID]; !ok {
+		s.cache.UserRemove(userID, cached.ID)
+	}
+	return cached, nil
+}
+
+// Refresh fetches the metadata and ratings of the film from its provider again
+func (s *service) Refresh(ctx context.Context, url string) (*film.Item, error) {
+	id := s.provider.ExtractID(url)
+	cached, ok := s.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	f, err := s.provider.Refresh(ctx, cached)
+	if err != nil {
+		return nil, errors.Wrap(err, "refresh film from provider")
+	}
+	if err := s.storage.Refresh(ctx, f); err != nil {
+		return nil, errors.Wrap(err, "update film in storage")
+	}
+
+	// the user data may have changed while the provider was answering
+	if current, ok := s.cache.Get(id); ok {
+		f.Scores, f.Statuses = current.Scores, current.Statuses
+		f.Comments, f.NoComments, f.CommentTimes = current.Comments, current.NoComments, current.CommentTimes
+	}
+	s.cache.Set(f)
+	return f, nil
+}
+
+// Comment adds the comment of the user, parentID is set for a reply
+func (s *service) Comment(ctx context.Context, userID, url, text, parentID string, spoiler bool) (*film.Item, error) {
 	f, err := s.get(ctx, url, true)
 	if err != nil {
 		return nil, err
 	}
+	if parentID != "" && commentIndex(f, parentID) < 0 {
+		return nil, ErrNoComment
+	}
 
 	if len(f.Comments) == 0 {
 		f.Comments = make([]film.Comment, 0, 10)
 	}
 
 	comment := film.Comment{
+		ParentID:  parentID,
 		UserID:    userID,
 		Text:      text,
+		Spoiler:   spoiler,
 		CreatedAt: time.Now(),
 	}
+	if err := s.storage.AddComment(ctx, f.ID, &comment); err != nil {
+		return nil, fmt.Errorf("add comment to storage: %+w", err)
+	}
 	f.Comments = append(f.Comments, comment)
 	f.NoComments = false
+	f.CommentTimes = copyCommentTimes(f.CommentTimes)
+	f.CommentTimes[comment.ID] = film.CommentTime{UserID: userID, CreatedAt: comment.CreatedAt}
+	f.UpdatedAt = time.Now()
+	s.cache.Set(f)
+	return f, nil
+}
 
-	if err := s.storage.AddComment(ctx, f.ID, &comment); err != nil {
-		return nil, fmt.Errorf("add comment to storage: %+w", err)
+// EditComment replaces the text of the comment and marks it as a spoiler if it is set, only the author or an admin can do it
+func (s *service) EditComment(ctx context.Context, userID, url, commentID, text string, spoiler *bool, admin bool) (*film.Item, error) {
+	return s.changeComment(ctx, url, commentID, func(c *film.Comment) error {
+		if c.UserID != userID && !admin {
+			return ErrForbidden
+		}
+		if c.Deleted {
+			return ErrNoComment
+		}
+		c.Text = text
+		if spoiler != nil {
+			c.Spoiler = *spoiler
+		}
+		c.EditedAt = time.Now()
+		return nil
+	})
+}
+
+// React toggles the emoji reaction of the user on the comment
+func (s *service) React(ctx context.Context, userID, url, commentID, emoji string) (*film.Item, error) {
+	return s.changeComment(ctx, url, commentID, func(c *film.Comment) error {
+		if c.Deleted {
+			return ErrNoComment
+		}
+		c.React(userID, emoji)
+		return nil
+	})
+}
+
+// DeleteComment removes the comment of the user, a comment with replies keeps its place without the text
+func (s *service) DeleteComment(ctx context.Context, userID, url, commentID string, admin bool) (*film.Item, error) {
+	f, err := s.get(ctx, url, true)
+	if err != nil {
+		return nil, err
+	}
+	i := commentIndex(f, commentID)
+	if i < 0 || f.Comments[i].Deleted {
+		return nil, ErrNoComment
+	}
+	if f.Comments[i].UserID != userID && !admin {
+		return nil, ErrForbidden
+	}
+
+	// the cached film shares the comments, so they are copied before the change
+	comments := append(make([]film.Comment, 0, len(f.Comments)), f.Comments...)
+	if hasReplies(f, commentID) {
+		comments[i].Text, comments[i].Reactions, comments[i].Deleted = "", nil, true
+		if err := s.storage.SetComment(ctx, f.ID, &comments[i]); err != nil {
+			return nil, errors.Wrap(err, "set comment in storage")
+		}
+	} else {
+		if err := s.storage.DeleteComment(ctx, f.ID, commentID); err != nil {
+			return nil, errors.Wrap(err, "delete comment from storage")
+		}
+		comments = append(comments[:i], comments[i+1:]...)
+	}
+
+	f.Comments = comments
+	f.NoComments = len(comments) == 0
+	f.CommentTimes = copyCommentTimes(f.CommentTimes)
+	delete(f.CommentTimes, commentID)
+	f.UpdatedAt = time.Now()
+	s.cache.Set(f)
+	return f, nil
+}
+
+// changeComment applies the change to a copy of the comment and saves it
+func (s *service) changeComment(ctx context.Context, url, commentID string, change func(c *film.Comment) error) (*film.Item, error) {
+	f, err := s.get(ctx, url, true)
+	if err != nil {
+		return nil, err
+	}
+	i := commentIndex(f, commentID)
+	if i < 0 {
+		return nil, ErrNoComment
+	}
+
+	comment := f.Comments[i]
+	comment.Reactions = make(map[string][]string, len(f.Comments[i].Reactions))
+	for emoji, users := range f.Comments[i].Reactions {
+		comment.Reactions[emoji] = users
+	}
+	if err := change(&comment); err != nil {
+		return nil, err
 	}
+	if err := s.storage.SetComment(ctx, f.ID, &comment); err != nil {
+		return nil, errors.Wrap(err, "set comment in storage")
+	}
+
+	f.Comments = append(make([]film.Comment, 0, len(f.Comments)), f.Comments...)
+	f.Comments[i] = comment
 	f.UpdatedAt = time.Now()
 	s.cache.Set(f)
 	return f, nil
 }
 
+// MarkRead remembers that the user has read all comments of the film
+func (s *service) MarkRead(ctx context.Context, userID, url string) (time.Time, error) {
+	id := s.provider.ExtractID(url)
+	if _, ok := s.cache.Get(id); !ok {
+		return time.Time{}, ErrNotFound
+	}
+
+	now := time.Now()
+	if err := s.storage.SetLastRead(ctx, userID, id, now); err != nil {
+		return time.Time{}, errors.Wrap(err, "set last read in storage")
+	}
+	s.cache.Read(userID, id, now)
+	return now, nil
+}
+
+// Unread counts comments of other users posted after the user read the film, films without them are omitted
+func (s *service) Unread(ctx context.Context, userID string) (map[string]int, error) {
+	reads, ok := s.cache.LastRead(userID)
+	if !ok {
+		var err error
+		if reads, err = s.storage.LastRead(ctx, userID); err != nil {
+			return nil, errors.Wrap(err, "get last read from storage")
+		}
+		s.cache.SetLastRead(userID, reads)
+	}
+
+	all, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unread := make(map[string]int)
+	for i := range all {
+		read := reads[all[i].ID]
+		for _, c := range all[i].CommentTimes {
+			if c.UserID != userID && c.CreatedAt.After(read) {
+				unread[all[i].ID]++
+			}
+		}
+	}
+	return unread, nil
+}
+
+// copyCommentTimes copies the times shared with the cached film, so they can be changed
+func copyCommentTimes(times film.CommentTimes) film.CommentTimes {
+	res := make(film.CommentTimes, len(times)+1)
+	for id, t := range times {
+		res[id] = t
+	}
+	return res
+}
+
+func commentIndex(f *film.Item, commentID string) int {
+	for i := range f.Comments {
+		if f.Comments[i].ID == commentID {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasReplies(f *film.Item, commentID string) bool {
+	for i := range f.Comments {
+		if f.Comments[i].ParentID == commentID {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *service) User(ctx context.Context, userID string) (film.Items, error) {
 	var err error
 	filmsID, ok := s.cache.User(userID)
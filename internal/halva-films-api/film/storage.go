@@ -2,7 +2,6 @@ package film
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -16,7 +15,10 @@ import (
 	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
 )
 
-const approximateFilmsNumber = 256
+const (
+	approximateFilmsNumber = 256
+	migrationCommentTimes  = "comment_times"
+)
 
 var ErrNotFound = errors.New("doc not found")
 
@@ -33,9 +35,10 @@ func NewStorage(client *firestore.Client) *storage {
 func (s *storage) Set(ctx context.Context, userID string, item *film.Item) error {
 	item.UpdatedAt = time.Now()
 	var (
-		score, ok = item.Scores[userID]
-		filmRef   = s.Collection(fire.FilmsCollection).Doc(item.ID)
-		userRef   = s.Collection(fire.UsersCollection).Doc(userID)
+		score, ok         = item.Scores[userID]
+		status, hasStatus = item.Statuses[userID]
+		filmRef           = s.Collection(fire.FilmsCollection).Doc(item.ID)
+		userRef           = s.Collection(fire.UsersCollection).Doc(userID)
 	)
 
 	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
@@ -57,6 +60,14 @@ func (s *storage) Set(ctx context.Context, userID string, item *film.Item) error
 		} else {
 			delete(u.Scores, item.ID)
 		}
+		if hasStatus {
+			if len(u.Statuses) == 0 {
+				u.Statuses = make(map[string]film.Status)
+			}
+			u.Statuses[item.ID] = status
+		} else {
+			delete(u.Statuses, item.ID)
+		}
 
 		if err := tx.Set(filmRef, item); err != nil {
 			return errors.Wrap(err, "tx set film doc")
@@ -67,9 +78,44 @@ func (s *storage) Set(ctx context.Context, userID string, item *film.Item) error
 	return errors.Wrap(err, "run set film transaction")
 }
 
+// Refresh updates only the metadata of the film, so concurrent score and status changes are not overwritten
+func (s *storage) Refresh(ctx context.Context, item *film.Item) error {
+	item.UpdatedAt = time.Now()
+	_, err := s.Collection(fire.FilmsCollection).Doc(item.ID).Update(ctx, []firestore.Update{
+		{Path: "title", Value: item.Title},
+		{Path: "title_original", Value: item.TitleOriginal},
+		{Path: "cover", Value: item.Poster},
+		{Path: "poster", Value: item.Cover},
+		{Path: "director", Value: item.Director},
+		{Path: "description", Value: item.Description},
+		{Path: "short_description", Value: item.ShortDescription},
+		{Path: "kinopoisk", Value: item.URL},
+		{Path: "provider", Value: item.Provider},
+		{Path: "kinopoisk_id", Value: item.KinopoiskID},
+		{Path: "imdb_id", Value: item.ImdbID},
+		{Path: "tmdb_id", Value: item.TmdbID},
+		{Path: "rating_kinopoisk", Value: item.RatingKinopoisk},
+		{Path: "rating_kinopoisk_vote_count", Value: item.RatingKinopoiskVoteCount},
+		{Path: "rating_imdb", Value: item.RatingImdb},
+		{Path: "rating_imdb_vote_count", Value: item.RatingImdbVoteCount},
+		{Path: "year", Value: item.Year},
+		{Path: "film_length", Value: item.FilmLength},
+		{Path: "serial", Value: item.Serial},
+		{Path: "short_film", Value: item.ShortFilm},
+		{Path: "genres", Value: item.Genres},
+		{Path: "refreshed_at", Value: item.RefreshedAt},
+		{Path: "updated_at", Value: item.UpdatedAt},
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "update film doc")
+}
+
 func (s *storage) Comments(ctx context.Context, filmID string) ([]film.Comment, error) {
 	comments := make([]film.Comment, 0, 10)
 	iter := s.Collection(fire.FilmsCollection).Doc(filmID).Collection(fire.CommentsCollection).Documents(ctx)
+	defer iter.Stop()
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -87,14 +133,120 @@ func (s *storage) Comments(ctx context.Context, filmID string) ([]film.Comment,
 	return comments, nil
 }
 
+// AddComment saves the comment and its time in the film
 func (s *storage) AddComment(ctx context.Context, filmID string, comment *film.Comment) error {
-	_, _, err := s.Collection(fire.FilmsCollection).Doc(filmID).Collection(fire.CommentsCollection).Add(ctx, comment)
+	filmRef := s.Collection(fire.FilmsCollection).Doc(filmID)
+	ref := filmRef.Collection(fire.CommentsCollection).NewDoc()
+	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Create(ref, comment); err != nil {
+			return errors.Wrap(err, "tx create comment doc")
+		}
+		return errors.Wrap(tx.Update(filmRef, []firestore.Update{commentTime(ref.ID, comment)}), "tx update comment time")
+	})
 	if err != nil {
-		return fmt.Errorf("add comment to collection: %+w", err)
+		return errors.Wrap(err, "run add comment transaction")
 	}
+	comment.ID = ref.ID
 	return nil
 }
 
+// SetComment saves the comment, the time of a deleted comment is removed from the film
+func (s *storage) SetComment(ctx context.Context, filmID string, comment *film.Comment) error {
+	filmRef := s.Collection(fire.FilmsCollection).Doc(filmID)
+	if !comment.Deleted {
+		_, err := filmRef.Collection(fire.CommentsCollection).Doc(comment.ID).Set(ctx, comment)
+		return errors.Wrap(err, "set comment doc")
+	}
+	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(filmRef.Collection(fire.CommentsCollection).Doc(comment.ID), comment); err != nil {
+			return errors.Wrap(err, "tx set comment doc")
+		}
+		return errors.Wrap(tx.Update(filmRef, []firestore.Update{commentTime(comment.ID, nil)}), "tx delete comment time")
+	})
+	return errors.Wrap(err, "run set comment transaction")
+}
+
+func (s *storage) DeleteComment(ctx context.Context, filmID, commentID string) error {
+	filmRef := s.Collection(fire.FilmsCollection).Doc(filmID)
+	err := s.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Delete(filmRef.Collection(fire.CommentsCollection).Doc(commentID)); err != nil {
+			return errors.Wrap(err, "tx delete comment doc")
+		}
+		return errors.Wrap(tx.Update(filmRef, []firestore.Update{commentTime(commentID, nil)}), "tx delete comment time")
+	})
+	return errors.Wrap(err, "run delete comment transaction")
+}
+
+// BackfillCommentTimes adds the times of the comments written before the films kept them,
+// it is done once, the films are updated with the found times
+func (s *storage) BackfillCommentTimes(ctx context.Context, films film.Items) error {
+	doneRef := s.Collection(fire.MigrationsCollection).Doc(migrationCommentTimes)
+	_, err := doneRef.Get(ctx)
+	switch {
+	case err == nil:
+		return nil
+	case status.Code(err) != codes.NotFound:
+		return errors.Wrap(err, "get migration doc")
+	}
+
+	byID := make(map[string]*film.Item, len(films))
+	for i := range films {
+		byID[films[i].ID] = &films[i]
+	}
+	updates := make(map[string][]firestore.Update)
+	iter := s.CollectionGroup(fire.CommentsCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "get next iterator")
+		}
+		c, err := film.ParseComment(doc)
+		if err != nil {
+			return errors.Wrap(err, "parse comment doc")
+		}
+		// comments of deleted films are skipped, the update would fail on them
+		f, ok := byID[doc.Ref.Parent.Parent.ID]
+		if !ok || c.Deleted {
+			continue
+		}
+		if _, ok := f.CommentTimes[c.ID]; ok {
+			continue
+		}
+		if f.CommentTimes == nil {
+			f.CommentTimes = make(film.CommentTimes)
+		}
+		f.CommentTimes[c.ID] = film.CommentTime{UserID: c.UserID, CreatedAt: c.CreatedAt}
+		updates[f.ID] = append(updates[f.ID], commentTime(c.ID, c))
+	}
+
+	batch, size := s.Batch(), 0
+	for filmID, u := range updates {
+		batch.Update(s.Collection(fire.FilmsCollection).Doc(filmID), u)
+		if size++; size == fire.BatchSize {
+			if _, err := batch.Commit(ctx); err != nil {
+				return errors.Wrap(err, "commit comment times batch")
+			}
+			batch, size = s.Batch(), 0
+		}
+	}
+	batch.Create(doneRef, map[string]interface{}{"done_at": time.Now()})
+	_, err = batch.Commit(ctx)
+	return errors.Wrap(err, "commit comment times batch")
+}
+
+// commentTime sets the time of the comment in the film or deletes it when the comment is nil
+func commentTime(commentID string, comment *film.Comment) firestore.Update {
+	path := firestore.FieldPath{"comment_times", commentID}
+	if comment == nil {
+		return firestore.Update{FieldPath: path, Value: firestore.Delete}
+	}
+	return firestore.Update{FieldPath: path, Value: film.CommentTime{UserID: comment.UserID, CreatedAt: comment.CreatedAt}}
+}
+
 func (s *storage) All(ctx context.Context) (film.Items, error) {
 	films := make(film.Items, 0, approximateFilmsNumber)
 	iter := s.Collection(fire.FilmsCollection).Documents(ctx)
@@ -128,9 +280,40 @@ func (s *storage) User(ctx context.Context, userID string) ([]string, error) {
 		return nil, errors.Wrap(err, "parse user doc")
 	}
 
-	films := make([]string, 0, len(u.Scores))
+	films := make([]string, 0, len(u.Scores)+len(u.Statuses))
 	for k, _ := range u.Scores {
 		films = append(films, k)
 	}
+	for k := range u.Statuses {
+		if _, ok := u.Scores[k]; !ok {
+			films = append(films, k)
+		}
+	}
 	return films, nil
 }
+
+// LastRead returns when the user read comments of films
+func (s *storage) LastRead(ctx context.Context, userID string) (map[string]time.Time, error) {
+	userDoc, err := s.Collection(fire.UsersCollection).Doc(userID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return map[string]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get user")
+	}
+	u, err := user.Parse(userDoc)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse user doc")
+	}
+	if u.LastRead == nil {
+		u.LastRead = map[string]time.Time{}
+	}
+	return u.LastRead, nil
+}
+
+func (s *storage) SetLastRead(ctx context.Context, userID, filmID string, at time.Time) error {
+	_, err := s.Collection(fire.UsersCollection).Doc(userID).Set(ctx, map[string]interface{}{
+		"last_read": map[string]interface{}{filmID: at},
+	}, firestore.MergeAll)
+	return errors.Wrap(err, "merge last read into user doc")
+}
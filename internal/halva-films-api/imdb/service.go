@@ -0,0 +1,218 @@
+package imdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	apiOMDb   = "https://www.omdbapi.com/"
+	titleURL  = "https://www.imdb.com/title/"
+	idPrefix  = "imdb-"
+	notGiven  = "N/A"
+	typeSerie = "series"
+)
+
+var (
+	ErrNotFound = errors.New("film not found on imdb")
+
+	urlRe = regexp.MustCompile(`^(?:https?://)?(?:www\.|m\.)?imdb\.com/(?:[a-z]{2}/)?title/(tt\d+)`)
+	idRe  = regexp.MustCompile(`^tt\d+$`)
+)
+
+// imdb gets IMDb data through the OMDb API
+type imdb struct {
+	apiKey string
+	client *http.Client
+}
+
+// New creates the provider, requests time out after 10 seconds if the timeout is 0
+func New(apiKey string, timeout time.Duration) *imdb {
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &imdb{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (i *imdb) Name() string {
+	return film.ProviderImdb
+}
+
+// Match returns the film ID of an imdb link or of a bare imdb ID
+func (i *imdb) Match(url string) (string, bool) {
+	url = strings.TrimSpace(url)
+	if m := urlRe.FindStringSubmatch(url); m != nil {
+		return idPrefix + m[1], true
+	}
+	if idRe.MatchString(url) {
+		return idPrefix + url, true
+	}
+	return "", false
+}
+
+func (i *imdb) GetFilm(ctx context.Context, url string) (*film.Item, error) {
+	id, ok := i.Match(url)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	resp, err := i.getFilm(ctx, strings.TrimPrefix(id, idPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return buildFilm(resp), nil
+}
+
+// Refresh fills the missing fields of the copy of the film and updates its imdb rating
+func (i *imdb) Refresh(ctx context.Context, f *film.Item) (*film.Item, error) {
+	resp, err := i.getFilm(ctx, f.ImdbID)
+	if err != nil {
+		return nil, err
+	}
+	fresh := buildFilm(resp)
+
+	merged := *f
+	if merged.Title == "" {
+		merged.Title = fresh.Title
+	}
+	if merged.TitleOriginal == "" {
+		merged.TitleOriginal = fresh.TitleOriginal
+	}
+	if merged.Poster == "" {
+		merged.Poster = fresh.Poster
+	}
+	if merged.Director == "" {
+		merged.Director = fresh.Director
+	}
+	if merged.Description == "" {
+		merged.Description = fresh.Description
+	}
+	if merged.Year == 0 {
+		merged.Year = fresh.Year
+	}
+	if merged.FilmLength == 0 {
+		merged.FilmLength = fresh.FilmLength
+	}
+	if len(merged.Genres) == 0 {
+		merged.Genres = fresh.Genres
+	}
+	merged.RatingImdb = fresh.RatingImdb
+	merged.RatingImdbVoteCount = fresh.RatingImdbVoteCount
+	merged.RefreshedAt = time.Now()
+	return &merged, nil
+}
+
+func (i *imdb) getFilm(ctx context.Context, imdbID string) (*filmResp, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiOMDb, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request get film from omdb")
+	}
+	q := req.URL.Query()
+	q.Add("i", imdbID)
+	q.Add("plot", "full")
+	q.Add("apikey", i.apiKey)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do http request")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("status not ok: " + string(data))
+	}
+
+	var f filmResp
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrap(err, "unmarshall film")
+	}
+	if f.Response != "True" {
+		if strings.Contains(f.Error, "not found") || strings.Contains(f.Error, "Incorrect IMDb ID") {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New("omdb error: " + f.Error)
+	}
+	return &f, nil
+}
+
+func buildFilm(f *filmResp) *film.Item {
+	var genres []string
+	for _, g := range strings.Split(given(f.Genre), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			genres = append(genres, g)
+		}
+	}
+	year, _ := strconv.Atoi(firstNumber(f.Year))
+	length, _ := strconv.Atoi(firstNumber(f.Runtime))
+	rating, _ := strconv.ParseFloat(given(f.ImdbRating), 64)
+	votes, _ := strconv.Atoi(strings.ReplaceAll(given(f.ImdbVotes), ",", ""))
+
+	return &film.Item{
+		ID:                  idPrefix + f.ImdbID,
+		Title:               f.Title,
+		TitleOriginal:       f.Title,
+		Poster:              given(f.Poster),
+		Director:            given(f.Director),
+		Description:         given(f.Plot),
+		URL:                 titleURL + f.ImdbID + "/",
+		Provider:            film.ProviderImdb,
+		ImdbID:              f.ImdbID,
+		RatingImdb:          rating,
+		RatingImdbVoteCount: votes,
+		Year:                year,
+		FilmLength:          length,
+		Serial:              f.Type == typeSerie,
+		Genres:              genres,
+	}
+}
+
+// given returns an empty string instead of the OMDb placeholder of unknown values
+func given(v string) string {
+	if v == notGiven {
+		return ""
+	}
+	return v
+}
+
+// firstNumber returns the leading digits of values like "136 min" or "2011–2019"
+func firstNumber(v string) string {
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	return v[:end]
+}
+
+type filmResp struct {
+	Title      string `json:"Title"`
+	Year       string `json:"Year"`
+	Runtime    string `json:"Runtime"`
+	Genre      string `json:"Genre"`
+	Director   string `json:"Director"`
+	Plot       string `json:"Plot"`
+	Poster     string `json:"Poster"`
+	ImdbRating string `json:"imdbRating"`
+	ImdbVotes  string `json:"imdbVotes"`
+	ImdbID     string `json:"imdbID"`
+	Type       string `json:"Type"`
+	Response   string `json:"Response"`
+	Error      string `json:"Error"`
+}
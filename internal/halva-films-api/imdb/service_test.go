@@ -0,0 +1,42 @@
+package imdb
+
+import "testing"
+
+func TestImdb_Match(t *testing.T) {
+	testCases := []string{
+		"https://www.imdb.com/title/tt0133093/",
+		"https://m.imdb.com/title/tt0133093/?ref_=nv_sr_srsg_0",
+		"imdb.com/ru/title/tt0133093",
+		"tt0133093",
+	}
+
+	i := imdb{}
+	for _, tc := range testCases {
+		if id, ok := i.Match(tc); !ok || id != "imdb-tt0133093" {
+			t.Errorf("%s: expected imdb-tt0133093, got: %s", tc, id)
+		}
+	}
+	if _, ok := i.Match("https://www.kinopoisk.ru/film/301/"); ok {
+		t.Error("expected kinopoisk links not to match")
+	}
+}
+
+func TestBuildFilm(t *testing.T) {
+	f := buildFilm(&filmResp{
+		Title:      "Breaking Bad",
+		Year:       "2008–2013",
+		Runtime:    "49 min",
+		Genre:      "Crime, Drama",
+		Director:   "N/A",
+		ImdbRating: "9.5",
+		ImdbVotes:  "2,100,000",
+		ImdbID:     "tt0903747",
+		Type:       "series",
+	})
+	if f.ID != "imdb-tt0903747" || f.Year != 2008 || f.FilmLength != 49 || !f.Serial {
+		t.Errorf("unexpected film: %+v", f)
+	}
+	if f.Director != "" || len(f.Genres) != 2 || f.RatingImdb != 9.5 || f.RatingImdbVoteCount != 2100000 {
+		t.Errorf("unexpected film details: %+v", f)
+	}
+}
@@ -0,0 +1,249 @@
+package kinopoisk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultURL     = "https://kinopoiskapiunofficial.tech/api/"
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 3
+	defaultBackoff = 500 * time.Millisecond
+	defaultRate    = 50 * time.Millisecond // the api allows 20 requests per second
+	defaultTTL     = 24 * time.Hour
+	maxRetryAfter  = time.Minute
+)
+
+var (
+	ErrNotFound      = errors.New("film not found on kinopoisk")
+	ErrQuotaExceeded = errors.New("kinopoisk quota exceeded")
+	ErrUnauthorized  = errors.New("kinopoisk api key is not accepted")
+)
+
+type Config struct {
+	URL      string        `yaml:"url"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Retries  *int          `yaml:"retries"`   // retries of a failed request, 0 disables them, 3 if it is not set
+	Backoff  time.Duration `yaml:"backoff"`   // pause before the first retry, doubled for every next one
+	Rate     time.Duration `yaml:"rate"`      // minimal pause between two requests
+	CacheDir string        `yaml:"cache_dir"` // responses are not cached on disk if empty
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type client struct {
+	apiKey  string
+	cfg     Config
+	retries int
+	http    *http.Client
+	cache   *diskCache
+
+	mx   sync.Mutex
+	next time.Time // the earliest time of the next request
+}
+
+func newClient(apiKey string, cfg Config) *client {
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	retries := defaultRetries
+	if cfg.Retries != nil {
+		retries = *cfg.Retries
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.Rate == 0 {
+		cfg.Rate = defaultRate
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = defaultTTL
+	}
+
+	c := &client{
+		apiKey:  apiKey,
+		cfg:     cfg,
+		retries: retries,
+		http:    &http.Client{Timeout: cfg.Timeout},
+	}
+	if cfg.CacheDir != "" {
+		c.cache = &diskCache{dir: cfg.CacheDir, ttl: cfg.CacheTTL}
+	}
+	return c
+}
+
+// get requests the api path retrying on rate limits and server errors, fresh skips cached responses
+func (c *client) get(ctx context.Context, path string, query url.Values, fresh bool) ([]byte, error) {
+	uri := c.cfg.URL + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	if !fresh {
+		if data, ok := c.cache.get(uri); ok {
+			return data, nil
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		data, retryAfter, err := c.do(ctx, uri)
+		if err == nil {
+			c.cache.set(uri, data)
+			return data, nil
+		}
+		if retryAfter < 0 || attempt >= c.retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		pause := c.cfg.Backoff << attempt
+		if retryAfter > pause {
+			pause = retryAfter
+		}
+		timer := time.NewTimer(pause)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do makes one request, a non-negative retryAfter means the request may be retried after the pause
+func (c *client) do(ctx context.Context, uri string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, -1, errors.Wrap(err, "create request to kp")
+	}
+	req.Header.Add(xAPIKeyHeader, c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "do http request")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "read body")
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return data, 0, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, -1, ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, -1, ErrUnauthorized
+	case resp.StatusCode == http.StatusPaymentRequired:
+		// the daily quota is over, there is no point in retrying today
+		return nil, -1, ErrQuotaExceeded
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, retryAfter(resp.Header.Get("Retry-After")), ErrQuotaExceeded
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, 0, errors.New("status not ok: " + strconv.Itoa(resp.StatusCode) + " " + string(data))
+	default:
+		return nil, -1, errors.New("status not ok: " + strconv.Itoa(resp.StatusCode) + " " + string(data))
+	}
+}
+
+// wait blocks until the request fits the rate
+func (c *client) wait(ctx context.Context) error {
+	c.mx.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.cfg.Rate)
+	c.mx.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// retryAfter parses the header given either in seconds or as a date
+func retryAfter(header string) time.Duration {
+	var d time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		d = time.Until(at)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
+
+// diskCache keeps successful responses in files named by the hash of the request, a nil cache is disabled
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func (c *diskCache) path(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *diskCache) get(uri string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	path := c.path(uri)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	return data, err == nil
+}
+
+// set stores the response, failures are ignored because the cache only saves requests
+func (c *diskCache) set(uri string, data []byte) {
+	if c == nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o750); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), c.path(uri)) != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,160 @@
+package kinopoisk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+func newMatrix(server *fakeKinopoisk) {
+	server.films["301"] = filmResp{
+		KinopoiskID:     301,
+		ImdbID:          "tt0133093",
+		NameRu:          "Матрица",
+		NameOriginal:    "The Matrix",
+		RatingKinopoisk: 8.5,
+		Year:            1999,
+		WebURL:          "https://www.kinopoisk.ru/film/301/",
+	}
+	server.staff["301"] = []staffResp{
+		{NameRu: "Лана Вачовски", ProfessionKey: ProfessionKeyDirector},
+		{NameRu: "Киану Ривз", ProfessionKey: "ACTOR"},
+		{NameEn: "Lilly Wachowski", ProfessionKey: ProfessionKeyDirector},
+	}
+}
+
+func TestKinopoisk_GetFilm(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	newMatrix(server)
+	kp := New(fakeAPIKey, server.config())
+
+	f, err := kp.GetFilm(context.Background(), "https://www.kinopoisk.ru/film/301/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != "301" || f.KinopoiskID != "301" || f.ImdbID != "tt0133093" || f.Provider != film.ProviderKinopoisk {
+		t.Errorf("unexpected film ids: %+v", f)
+	}
+	if f.Director != "Лана Вачовски, Lilly Wachowski" {
+		t.Errorf("unexpected directors: %s", f.Director)
+	}
+}
+
+func TestKinopoisk_Errors(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	newMatrix(server)
+	ctx := context.Background()
+
+	if _, err := New(fakeAPIKey, server.config()).GetFilm(ctx, "404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+	if _, err := New("stolen", server.config()).GetFilm(ctx, "301"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got: %v", err)
+	}
+
+	server.fail(http.StatusPaymentRequired)
+	before := server.count()
+	if _, err := New(fakeAPIKey, server.config()).GetFilm(ctx, "301"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got: %v", err)
+	}
+	if n := server.count() - before; n != 1 {
+		t.Errorf("expected the exhausted quota not to be retried, got %d requests", n)
+	}
+}
+
+func TestKinopoisk_Retries(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	newMatrix(server)
+	kp := New(fakeAPIKey, server.config())
+	ctx := context.Background()
+
+	server.fail(http.StatusTooManyRequests, http.StatusBadGateway)
+	if _, err := kp.GetFilm(ctx, "301"); err != nil {
+		t.Fatalf("expected the request to succeed after retries, got: %v", err)
+	}
+	if n := server.count(); n != 4 {
+		t.Errorf("expected 2 failed and 2 successful requests, got: %d", n)
+	}
+
+	server.fail(http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	if _, err := kp.GetFilm(ctx, "301"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded after all retries, got: %v", err)
+	}
+
+	cfg, noRetries := server.config(), 0
+	cfg.Retries = &noRetries
+	server.fail(http.StatusBadGateway)
+	before := server.count()
+	if _, err := New(fakeAPIKey, cfg).GetFilm(ctx, "301"); err == nil {
+		t.Error("expected the failed request not to be retried")
+	}
+	if n := server.count() - before; n != 1 {
+		t.Errorf("expected a single request without retries, got: %d", n)
+	}
+}
+
+func TestKinopoisk_Cache(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	newMatrix(server)
+	cfg := server.config()
+	cfg.CacheDir = t.TempDir()
+	kp := New(fakeAPIKey, cfg)
+	ctx := context.Background()
+
+	f, err := kp.GetFilm(ctx, "301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(fakeAPIKey, cfg).GetFilm(ctx, "301"); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.count(); n != 2 {
+		t.Errorf("expected the second film to be read from the disk, got %d requests", n)
+	}
+
+	server.mx.Lock()
+	server.films["301"] = filmResp{KinopoiskID: 301, RatingKinopoisk: 8.7}
+	server.mx.Unlock()
+	refreshed, err := kp.Refresh(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RatingKinopoisk != 8.7 || refreshed.Title != "Матрица" {
+		t.Errorf("expected refresh to skip the cache and keep the film data, got: %+v", refreshed)
+	}
+}
+
+func TestKinopoisk_Search(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	server.found = []foundResp{
+		{FilmID: 301, NameRu: "Матрица", NameEn: "The Matrix", Type: "FILM", Year: "1999", Rating: "8.5"},
+		{FilmID: 298, NameRu: "Матрица: Перезагрузка", Type: "FILM", Year: "2003", Rating: "null"},
+		{FilmID: 404900, NameRu: "Во все тяжкие", Type: typeSeries, Year: "2008-2013"},
+	}
+	kp := New(fakeAPIKey, server.config())
+
+	found, err := kp.Search(context.Background(), "матрица")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 films, got: %+v", found)
+	}
+	if found[0].ID != "301" || found[0].Year != 1999 || found[0].RatingKinopoisk != 8.5 || found[0].URL != "https://www.kinopoisk.ru/film/301/" {
+		t.Errorf("unexpected film: %+v", found[0])
+	}
+	if found[1].RatingKinopoisk != 0 || found[1].Serial {
+		t.Errorf("expected an unrated film, got: %+v", found[1])
+	}
+
+	series, err := kp.Search(context.Background(), "тяжкие")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || !series[0].Serial || series[0].Year != 2008 {
+		t.Errorf("expected a series, got: %+v", series)
+	}
+}
@@ -0,0 +1,97 @@
+package kinopoisk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fakeAPIKey = "secret"
+
+// fakeKinopoisk serves films and staff like the unofficial kinopoisk api does
+type fakeKinopoisk struct {
+	*httptest.Server
+
+	mx       sync.Mutex
+	films    map[string]filmResp
+	staff    map[string][]staffResp
+	found    []foundResp
+	failures []int // statuses answered before the real responses
+	requests int
+}
+
+func newFakeKinopoisk(t *testing.T) *fakeKinopoisk {
+	f := &fakeKinopoisk{
+		films: make(map[string]filmResp),
+		staff: make(map[string][]staffResp),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeKinopoisk) config() Config {
+	retries := 2
+	return Config{URL: f.URL + "/api/", Retries: &retries, Backoff: 1, Rate: 1}
+}
+
+func (f *fakeKinopoisk) fail(statuses ...int) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.failures = append(f.failures, statuses...)
+}
+
+func (f *fakeKinopoisk) count() int {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.requests
+}
+
+func (f *fakeKinopoisk) serve(w http.ResponseWriter, r *http.Request) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.requests++
+
+	if r.Header.Get(xAPIKeyHeader) != fakeAPIKey {
+		http.Error(w, `{"message":"You don't have permissions"}`, http.StatusUnauthorized)
+		return
+	}
+	if len(f.failures) > 0 {
+		status := f.failures[0]
+		f.failures = f.failures[1:]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	var body any
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/"+apiFilms):
+		film, ok := f.films[strings.TrimPrefix(r.URL.Path, "/api/"+apiFilms)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body = film
+	case r.URL.Path == "/api/"+apiStaff:
+		body = f.staff[r.URL.Query().Get("filmId")]
+	case r.URL.Path == "/api/"+apiSearch:
+		resp := searchResp{Keyword: r.URL.Query().Get("keyword"), PagesCount: 1}
+		for i := range f.found {
+			if strings.Contains(strings.ToLower(f.found[i].NameRu), strings.ToLower(resp.Keyword)) {
+				resp.Films = append(resp.Films, f.found[i])
+			}
+		}
+		body = resp
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -3,9 +3,11 @@ package kinopoisk
 import (
 	"context"
 	"encoding/json"
-	"io"
-	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,28 +16,49 @@ import (
 
 const (
 	filmURL               = `https://www.kinopoisk.ru/film/`
-	seriesURL             = `https://www.kinopoisk.ru/series/`
-	apiFilms              = "https://kinopoiskapiunofficial.tech/api/v2.2/films/"
-	apiStaff              = "https://kinopoiskapiunofficial.tech/api/v1/staff"
+	apiFilms              = "v2.2/films/"
+	apiStaff              = "v1/staff"
+	apiSearch             = "v2.1/films/search-by-keyword"
 	xAPIKeyHeader         = "X-API-KEY"
 	ProfessionKeyDirector = "DIRECTOR"
+	typeSeries            = "TV_SERIES"
+	typeMiniSeries        = "MINI_SERIES"
 )
 
+var urlRe = regexp.MustCompile(`^(?:https?://)?(?:www\.)?kinopoisk\.ru/(?:film|series)/(\d+)`)
+
 type kinopoisk struct {
-	apiKey string
-	client *http.Client
+	client *client
 }
 
-func New(apiKey string) *kinopoisk {
+func New(apiKey string, cfg Config) *kinopoisk {
 	return &kinopoisk{
-		apiKey: apiKey,
-		client: &http.Client{},
+		client: newClient(apiKey, cfg),
+	}
+}
+
+func (k *kinopoisk) Name() string {
+	return film.ProviderKinopoisk
+}
+
+// Match returns the film ID of a kinopoisk link or of a bare kinopoisk ID
+func (k *kinopoisk) Match(url string) (string, bool) {
+	url = strings.TrimSpace(url)
+	if m := urlRe.FindStringSubmatch(url); m != nil {
+		return m[1], true
 	}
+	if _, err := strconv.Atoi(url); err == nil {
+		return url, true
+	}
+	return "", false
 }
 
 func (k *kinopoisk) GetFilm(ctx context.Context, url string) (*film.Item, error) {
-	id := k.ExtractID(url)
-	kf, err := k.getFilm(ctx, id)
+	id, ok := k.Match(url)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	kf, err := k.getFilm(ctx, id, false)
 	if err != nil {
 		return nil, err
 	}
@@ -48,23 +71,70 @@ func (k *kinopoisk) GetFilm(ctx context.Context, url string) (*film.Item, error)
 	return f, nil
 }
 
-func (k *kinopoisk) getFilm(ctx context.Context, id string) (*filmResp, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiFilms+id, nil)
+// Refresh fetches the film again and merges the fresh data into it without touching user data
+func (k *kinopoisk) Refresh(ctx context.Context, f *film.Item) (*film.Item, error) {
+	id := f.KinopoiskID
+	if id == "" {
+		id = f.ID
+	}
+	kf, err := k.getFilm(ctx, id, true)
 	if err != nil {
-		return nil, errors.Wrap(err, "create request get film from kp")
+		return nil, err
+	}
+	merged := MergeFilm(kf, f)
+	if merged.Director == "" {
+		if merged.Director, err = k.getDirectors(ctx, id); err != nil {
+			return nil, err
+		}
 	}
+	return merged, nil
+}
 
-	req.Header.Add(xAPIKeyHeader, k.apiKey)
-	resp, err := k.client.Do(req)
+// Search finds films by keywords, only the first page of the results is returned
+func (k *kinopoisk) Search(ctx context.Context, query string) (film.Items, error) {
+	data, err := k.client.get(ctx, apiSearch, url.Values{"keyword": []string{query}, "page": []string{"1"}}, false)
 	if err != nil {
-		return nil, errors.Wrap(err, "do http request")
+		return nil, errors.Wrap(err, "search films in kp")
 	}
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "read body")
+
+	var found searchResp
+	if err := json.Unmarshal(data, &found); err != nil {
+		return nil, errors.Wrap(err, "unmarshall search results")
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("status not ok: " + string(data))
+
+	res := make(film.Items, 0, len(found.Films))
+	for i := range found.Films {
+		kf := &found.Films[i]
+		id := strconv.Itoa(kf.FilmID)
+		year, _ := strconv.Atoi(strings.Split(kf.Year, "-")[0])
+		rating, _ := strconv.ParseFloat(kf.Rating, 64)
+		var genres []string
+		for j := range kf.Genres {
+			genres = append(genres, kf.Genres[j].Genre)
+		}
+		res = append(res, film.Item{
+			ID:              id,
+			Title:           kf.NameRu,
+			TitleOriginal:   kf.NameEn,
+			Poster:          kf.PosterURLPreview,
+			Description:     kf.Description,
+			URL:             filmURL + id + "/",
+			Provider:        film.ProviderKinopoisk,
+			KinopoiskID:     id,
+			RatingKinopoisk: rating,
+			Year:            year,
+			Serial:          kf.Type == typeSeries || kf.Type == typeMiniSeries,
+			Genres:          genres,
+		})
+	}
+	return res, nil
+}
+
+// getFilm requests the film, fresh skips the cached response to get the current ratings
+func (k *kinopoisk) getFilm(ctx context.Context, id string, fresh bool) (*filmResp, error) {
+	data, err := k.client.get(ctx, apiFilms+url.PathEscape(id), nil, fresh)
+	if err != nil {
+		return nil, errors.Wrap(err, "get film from kp")
 	}
 
 	var kp filmResp
@@ -75,25 +145,9 @@ func (k *kinopoisk) getFilm(ctx context.Context, id string) (*filmResp, error) {
 }
 
 func (k *kinopoisk) getDirectors(ctx context.Context, id string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiStaff, nil)
-	if err != nil {
-		return "", errors.Wrap(err, "create request get film directors from kp")
-	}
-	req.Header.Add(xAPIKeyHeader, k.apiKey)
-	q := req.URL.Query()
-	q.Add("filmId", id)
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := k.client.Do(req)
-	if err != nil {
-		return "", errors.Wrap(err, "do http request")
-	}
-	data, err := io.ReadAll(resp.Body)
+	data, err := k.client.get(ctx, apiStaff, url.Values{"filmId": []string{id}}, false)
 	if err != nil {
-		return "", errors.Wrap(err, "read body")
-	}
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("status not ok: " + string(data))
+		return "", errors.Wrap(err, "get film directors from kp")
 	}
 
 	var staff []staffResp
@@ -120,8 +174,12 @@ func (k *kinopoisk) buildFilm(kf *filmResp) *film.Item {
 	for i := range kf.Genres {
 		genres = append(genres, kf.Genres[i].Genre)
 	}
+	id, ok := k.Match(kf.WebURL)
+	if !ok {
+		id = strconv.Itoa(kf.KinopoiskID)
+	}
 	return &film.Item{
-		ID:                       k.ExtractID(kf.WebURL),
+		ID:                       id,
 		Title:                    kf.NameRu,
 		TitleOriginal:            kf.NameOriginal,
 		Poster:                   kf.PosterURL,
@@ -129,6 +187,9 @@ func (k *kinopoisk) buildFilm(kf *filmResp) *film.Item {
 		Description:              kf.Description,
 		ShortDescription:         kf.ShortDescription,
 		URL:                      kf.WebURL,
+		Provider:                 film.ProviderKinopoisk,
+		KinopoiskID:              id,
+		ImdbID:                   kf.ImdbID,
 		RatingKinopoisk:          kf.RatingKinopoisk,
 		RatingKinopoiskVoteCount: kf.RatingKinopoiskVoteCount,
 		RatingImdb:               kf.RatingImdb,
@@ -141,69 +202,58 @@ func (k *kinopoisk) buildFilm(kf *filmResp) *film.Item {
 	}
 }
 
+// MergeFilm fills the missing fields of the copy of f and updates its ratings, scores, statuses and comments are kept as is
 func MergeFilm(kf *filmResp, f *film.Item) *film.Item {
-	if f.Title == "" {
-		f.Title = kf.NameRu
+	merged := *f
+	if merged.Title == "" {
+		merged.Title = kf.NameRu
+	}
+	if merged.TitleOriginal == "" {
+		merged.TitleOriginal = kf.NameOriginal
 	}
-	if f.TitleOriginal == "" {
-		f.TitleOriginal = kf.NameOriginal
+	if merged.Poster == "" {
+		merged.Poster = kf.PosterURL
 	}
-	if f.Poster == "" {
-		f.Poster = kf.PosterURL
+	if merged.Cover == "" {
+		merged.Cover = kf.CoverURL
 	}
-	if f.Cover == "" {
-		f.Cover = kf.CoverURL
+	if merged.Description == "" {
+		merged.Description = kf.Description
 	}
-	if f.Description == "" {
-		f.Description = kf.Description
+	if merged.ShortDescription == "" {
+		merged.ShortDescription = kf.ShortDescription
 	}
-	if f.URL == "" {
-		f.URL = kf.WebURL
+	if merged.URL == "" {
+		merged.URL = kf.WebURL
 	}
-	if f.Year == 0 {
-		f.Year = kf.Year
+	if merged.Provider == "" {
+		merged.Provider = film.ProviderKinopoisk
 	}
-	if f.FilmLength == 0 {
-		f.FilmLength = kf.FilmLength
+	if merged.KinopoiskID == "" && kf.KinopoiskID != 0 {
+		merged.KinopoiskID = strconv.Itoa(kf.KinopoiskID)
 	}
-	if len(f.Genres) == 0 {
+	if merged.ImdbID == "" {
+		merged.ImdbID = kf.ImdbID
+	}
+	if merged.Year == 0 {
+		merged.Year = kf.Year
+	}
+	if merged.FilmLength == 0 {
+		merged.FilmLength = kf.FilmLength
+	}
+	if len(merged.Genres) == 0 {
 		for i := range kf.Genres {
-			f.Genres = append(f.Genres, kf.Genres[i].Genre)
+			merged.Genres = append(merged.Genres, kf.Genres[i].Genre)
 		}
 	}
-	return &film.Item{
-		ID:                       f.ID,
-		Title:                    f.Title,
-		TitleOriginal:            f.TitleOriginal,
-		Poster:                   f.Poster,
-		Cover:                    f.Cover,
-		Director:                 f.Director,
-		Description:              f.Description,
-		Duration:                 f.Duration,
-		Scores:                   f.Scores,
-		Comments:                 f.Comments,
-		NoComments:               f.NoComments,
-		URL:                      f.URL,
-		RatingKinopoisk:          kf.RatingKinopoisk,
-		RatingKinopoiskVoteCount: kf.RatingKinopoiskVoteCount,
-		RatingImdb:               kf.RatingImdb,
-		RatingImdbVoteCount:      kf.RatingImdbVoteCount,
-		Year:                     f.Year,
-		FilmLength:               f.FilmLength,
-		Serial:                   kf.Serial,
-		ShortFilm:                kf.ShortFilm,
-		Genres:                   f.Genres,
-	}
-}
-
-func (k *kinopoisk) ExtractID(uri string) string {
-	id := strings.TrimPrefix(uri, filmURL)
-	id = strings.TrimPrefix(id, seriesURL)
-	id = strings.TrimSuffix(id, "\n")
-	id = strings.Split(id, "?")[0]
-	id = strings.Split(id, "&")[0]
-	id = strings.Split(id, "#")[0]
-	return strings.Split(id, "/")[0]
+	merged.RatingKinopoisk = kf.RatingKinopoisk
+	merged.RatingKinopoiskVoteCount = kf.RatingKinopoiskVoteCount
+	merged.RatingImdb = kf.RatingImdb
+	merged.RatingImdbVoteCount = kf.RatingImdbVoteCount
+	merged.Serial = kf.Serial
+	merged.ShortFilm = kf.ShortFilm
+	merged.RefreshedAt = time.Now()
+	return &merged
 }
 
 type filmResp struct {
@@ -228,6 +278,26 @@ type filmResp struct {
 	WebURL                   string  `json:"webUrl"`
 }
 
+type searchResp struct {
+	Keyword    string      `json:"keyword"`
+	PagesCount int         `json:"pagesCount"`
+	Films      []foundResp `json:"films"`
+}
+
+type foundResp struct {
+	FilmID           int     `json:"filmId"`
+	NameRu           string  `json:"nameRu"`
+	NameEn           string  `json:"nameEn"`
+	Type             string  `json:"type"`
+	Year             string  `json:"year"`
+	Description      string  `json:"description"`
+	FilmLength       string  `json:"filmLength"`
+	Genres           []genre `json:"genres"`
+	Rating           string  `json:"rating"`
+	PosterURL        string  `json:"posterUrl"`
+	PosterURLPreview string  `json:"posterUrlPreview"`
+}
+
 type genre struct {
 	Genre string `json:"genre"`
 }
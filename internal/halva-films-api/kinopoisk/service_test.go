@@ -1,21 +1,66 @@
 package kinopoisk
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+func TestKinopoisk_Match(t *testing.T) {
+	server := newFakeKinopoisk(t)
+	newMatrix(server)
+	kp := New(fakeAPIKey, server.config())
 
-func TestKinopoisk_ExtractID(t *testing.T) {
-	id := "1209850"
 	testCases := []string{
-		"https://www.kinopoisk.ru/series/1209850/",
-		"https://www.kinopoisk.ru/film/1209850/",
-		"https://www.kinopoisk.ru/series/1209850/?utm_referrer=www.google.com",
-		"https://www.kinopoisk.ru/film/1209850/?utm_referrer=www.google.com",
+		"https://www.kinopoisk.ru/film/301/",
+		"https://www.kinopoisk.ru/series/301/",
+		"https://www.kinopoisk.ru/film/301/?utm_referrer=www.google.com",
+		"https://kinopoisk.ru/film/301/",
+		"http://www.kinopoisk.ru/film/301",
+		"kinopoisk.ru/film/301",
+		" 301\n",
+	}
+	for _, url := range testCases {
+		t.Run(url, func(t *testing.T) {
+			if id, ok := kp.Match(url); !ok || id != "301" {
+				t.Errorf("expected Match to return 301, got: %s %v", id, ok)
+			}
+			f, err := kp.GetFilm(context.Background(), url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.ID != "301" {
+				t.Errorf("expected GetFilm to return 301, got: %s", f.ID)
+			}
+		})
+	}
+
+	if _, err := kp.GetFilm(context.Background(), "https://www.imdb.com/title/tt0133093/"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a link of another provider, got: %v", err)
 	}
+}
+
+func TestMergeFilm(t *testing.T) {
+	f := &film.Item{
+		ID:              "1209850",
+		Title:           "Своё название",
+		RatingKinopoisk: 7.1,
+		Scores:          map[string]film.Score{"a": film.GoodScore},
+		Statuses:        map[string]film.Status{"b": film.StatusWant},
+		AddedBy:         "a",
+	}
+	kf := &filmResp{NameRu: "Название", Year: 2020, RatingKinopoisk: 8.2, Genres: []genre{{Genre: "драма"}}}
 
-	kp := kinopoisk{}
-	for i := range testCases {
-		got := kp.ExtractID(testCases[i])
-		if got != id {
-			t.Errorf("expected: %s, got: %s", id, got)
-		}
+	merged := MergeFilm(kf, f)
+	if merged.Title != "Своё название" || merged.Year != 2020 || merged.RatingKinopoisk != 8.2 || len(merged.Genres) != 1 {
+		t.Errorf("expected missing fields to be filled and ratings updated, got: %+v", merged)
+	}
+	if merged.Scores["a"] != film.GoodScore || merged.Statuses["b"] != film.StatusWant || merged.AddedBy != "a" {
+		t.Errorf("expected user data to be kept, got: %+v", merged)
+	}
+	if merged.RefreshedAt.IsZero() || f.RatingKinopoisk != 7.1 {
+		t.Errorf("expected a refreshed copy, got: %+v, original: %+v", merged, f)
 	}
 }
@@ -0,0 +1,172 @@
+package planner
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	wantWeight   = 1.0
+	ratingWeight = 0.5
+	maxRating    = 10.0
+)
+
+var ErrNoCandidates = errors.New("no films to pick from")
+
+type filmService interface {
+	All(ctx context.Context) (film.Items, error)
+}
+
+// Options of a movie night, zero values disable the limits
+type Options struct {
+	Users     []string
+	MinRating float64
+	MinLength int
+	MaxLength int
+}
+
+type Candidate struct {
+	Film        film.Item
+	Weight      float64
+	SuggestedBy []string
+}
+
+type service struct {
+	film filmService
+
+	mx       sync.Mutex
+	rand     *rand.Rand
+	lastPick map[string]struct{} // suggesters of the previous fair pick
+}
+
+func New(films filmService) *service {
+	return &service{
+		film: films,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Candidates returns films that none of the attendees has scored, watched or dropped, best first
+func (s *service) Candidates(ctx context.Context, opts Options) ([]Candidate, error) {
+	all, err := s.film.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get all films")
+	}
+
+	candidates := make([]Candidate, 0, len(all))
+	for i := range all {
+		if c, ok := candidate(&all[i], &opts); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Weight == candidates[j].Weight {
+			return candidates[i].Film.Title < candidates[j].Film.Title
+		}
+		return candidates[i].Weight > candidates[j].Weight
+	})
+	return candidates, nil
+}
+
+// Pick draws a candidate with a chance proportional to its weight.
+// Films suggested only by the people of the previous pick are skipped while there are others.
+func (s *service) Pick(ctx context.Context, opts Options) (*Candidate, []Candidate, error) {
+	candidates, err := s.Candidates(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil, ErrNoCandidates
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	fair := make([]Candidate, 0, len(candidates))
+	for i := range candidates {
+		if !s.suggestedByLast(&candidates[i]) {
+			fair = append(fair, candidates[i])
+		}
+	}
+	if len(fair) == 0 {
+		fair = candidates
+	}
+
+	picked := weightedPick(s.rand, fair)
+	s.lastPick = make(map[string]struct{}, len(picked.SuggestedBy))
+	for _, userID := range picked.SuggestedBy {
+		s.lastPick[userID] = struct{}{}
+	}
+	return picked, candidates, nil
+}
+
+func (s *service) suggestedByLast(c *Candidate) bool {
+	if len(c.SuggestedBy) == 0 || len(s.lastPick) == 0 {
+		return false
+	}
+	for _, userID := range c.SuggestedBy {
+		if _, ok := s.lastPick[userID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func candidate(f *film.Item, opts *Options) (Candidate, bool) {
+	if opts.MinLength > 0 && f.FilmLength > 0 && f.FilmLength < opts.MinLength {
+		return Candidate{}, false
+	}
+	if opts.MaxLength > 0 && f.FilmLength > opts.MaxLength {
+		return Candidate{}, false
+	}
+	rating := math.Max(f.RatingKinopoisk, f.RatingImdb)
+	if opts.MinRating > 0 && rating < opts.MinRating {
+		return Candidate{}, false
+	}
+
+	c := Candidate{Film: *f}
+	for _, userID := range opts.Users {
+		if _, ok := f.Scores[userID]; ok {
+			return Candidate{}, false
+		}
+		switch f.Statuses[userID] {
+		case film.StatusWatched, film.StatusDropped:
+			return Candidate{}, false
+		case film.StatusWant, film.StatusWatching:
+			c.SuggestedBy = append(c.SuggestedBy, userID)
+		default:
+			if f.AddedBy == userID {
+				c.SuggestedBy = append(c.SuggestedBy, userID)
+			}
+		}
+	}
+	c.Weight = wantWeight*float64(len(c.SuggestedBy)) + ratingWeight*rating/maxRating
+	return c, true
+}
+
+func weightedPick(r *rand.Rand, candidates []Candidate) *Candidate {
+	var total float64
+	for i := range candidates {
+		total += candidates[i].Weight
+	}
+	if total <= 0 {
+		return &candidates[r.Intn(len(candidates))]
+	}
+
+	point := r.Float64() * total
+	for i := range candidates {
+		point -= candidates[i].Weight
+		if point < 0 {
+			return &candidates[i]
+		}
+	}
+	return &candidates[len(candidates)-1]
+}
@@ -0,0 +1,62 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeFilms film.Items
+
+func (f fakeFilms) All(context.Context) (film.Items, error) {
+	return film.Items(f), nil
+}
+
+func TestService_Candidates(t *testing.T) {
+	s := New(fakeFilms{
+		{ID: "scored", Title: "Scored", Scores: map[string]film.Score{"a": film.GoodScore}},
+		{ID: "dropped", Title: "Dropped", Statuses: map[string]film.Status{"b": film.StatusDropped}},
+		{ID: "long", Title: "Long", FilmLength: 200},
+		{ID: "wanted", Title: "Wanted", RatingKinopoisk: 6, Statuses: map[string]film.Status{"a": film.StatusWant, "b": film.StatusWant}},
+		{ID: "rated", Title: "Rated", RatingImdb: 9, AddedBy: "b"},
+		{ID: "plain", Title: "Plain", RatingKinopoisk: 7},
+	})
+
+	got, err := s.Candidates(context.Background(), Options{Users: []string{"a", "b"}, MaxLength: 180})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"wanted", "rated", "plain"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d candidates, got: %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Film.ID != want[i] {
+			t.Errorf("position %d: expected %s, got: %s", i, want[i], got[i].Film.ID)
+		}
+	}
+}
+
+func TestService_PickFair(t *testing.T) {
+	s := New(fakeFilms{
+		{ID: "first", Title: "First", Statuses: map[string]film.Status{"a": film.StatusWant}},
+		{ID: "second", Title: "Second", Statuses: map[string]film.Status{"b": film.StatusWant}},
+	})
+
+	opts := Options{Users: []string{"a", "b"}}
+	prev, _, err := s.Pick(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		next, _, err := s.Pick(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.Film.ID == prev.Film.ID {
+			t.Fatalf("suggestion of %v was picked twice in a row", next.SuggestedBy)
+		}
+		prev = next
+	}
+}
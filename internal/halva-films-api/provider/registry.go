@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const manualPrefix = "manual-"
+
+var ErrUnknownProvider = errors.New("no provider knows the link")
+
+// Provider is a source of film metadata
+type Provider interface {
+	Name() string
+	// Match returns the film ID for the link if the provider knows it
+	Match(url string) (string, bool)
+	GetFilm(ctx context.Context, url string) (*film.Item, error)
+	Refresh(ctx context.Context, f *film.Item) (*film.Item, error)
+}
+
+// Searcher is a provider able to find films by keywords
+type Searcher interface {
+	Search(ctx context.Context, query string) (film.Items, error)
+}
+
+type registry struct {
+	providers []Provider
+	byName    map[string]Provider
+}
+
+// New registers the providers, the first one matching a link is used
+func New(providers ...Provider) *registry {
+	r := &registry{
+		providers: providers,
+		byName:    make(map[string]Provider, len(providers)),
+	}
+	for _, p := range providers {
+		r.byName[p.Name()] = p
+	}
+	return r
+}
+
+func (r *registry) match(url string) (Provider, string, bool) {
+	for _, p := range r.providers {
+		if id, ok := p.Match(url); ok {
+			return p, id, true
+		}
+	}
+	return nil, "", false
+}
+
+// ExtractID returns the film ID of the link, anything no provider knows is considered to be a film ID already
+func (r *registry) ExtractID(url string) string {
+	if _, id, ok := r.match(url); ok {
+		return id
+	}
+	return strings.TrimSpace(url)
+}
+
+func (r *registry) GetFilm(ctx context.Context, url string) (*film.Item, error) {
+	p, id, ok := r.match(url)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	f, err := p.GetFilm(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "get film from "+p.Name())
+	}
+	f.ID, f.Provider = id, p.Name()
+	return f, nil
+}
+
+// Search finds films on the sites of all providers able to search, results of each provider keep their order
+func (r *registry) Search(ctx context.Context, query string) (film.Items, error) {
+	var res film.Items
+	for _, p := range r.providers {
+		s, ok := p.(Searcher)
+		if !ok {
+			continue
+		}
+		found, err := s.Search(ctx, query)
+		if err != nil {
+			return nil, errors.Wrap(err, "search films on "+p.Name())
+		}
+		for i := range found {
+			found[i].Provider = p.Name()
+		}
+		res = append(res, found...)
+	}
+	return res, nil
+}
+
+// Refresh updates the film from the provider it was added with, manual films are only marked as refreshed
+func (r *registry) Refresh(ctx context.Context, f *film.Item) (*film.Item, error) {
+	name := f.Provider
+	if name == "" {
+		name = film.ProviderKinopoisk
+	}
+	if name == film.ProviderManual {
+		refreshed := *f
+		refreshed.RefreshedAt = time.Now()
+		return &refreshed, nil
+	}
+
+	p, ok := r.byName[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	refreshed, err := p.Refresh(ctx, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "refresh film from "+name)
+	}
+	return refreshed, nil
+}
+
+// Manual returns a copy of the film entered by hand with a new ID
+func (r *registry) Manual(f *film.Item) (*film.Item, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generate film id")
+	}
+	manual := *f
+	manual.ID = manualPrefix + hex.EncodeToString(b)
+	manual.Provider = film.ProviderManual
+	manual.RefreshedAt = time.Now()
+	return &manual, nil
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeProvider struct {
+	name   string
+	prefix string
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Match(url string) (string, bool) {
+	if strings.HasPrefix(url, p.prefix) {
+		return p.name + "-" + strings.TrimPrefix(url, p.prefix), true
+	}
+	return "", false
+}
+
+func (p *fakeProvider) GetFilm(_ context.Context, url string) (*film.Item, error) {
+	return &film.Item{Title: url}, nil
+}
+
+func (p *fakeProvider) Refresh(_ context.Context, f *film.Item) (*film.Item, error) {
+	refreshed := *f
+	refreshed.Description = "by " + p.name
+	return &refreshed, nil
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := New(&fakeProvider{name: film.ProviderKinopoisk, prefix: "kp/"}, &fakeProvider{name: film.ProviderImdb, prefix: "imdb/"})
+
+	if id := r.ExtractID("imdb/tt1"); id != "imdb-tt1" {
+		t.Errorf("expected the imdb film id, got: %s", id)
+	}
+	if id := r.ExtractID(" manual-42 "); id != "manual-42" {
+		t.Errorf("expected an unknown link to be the film id, got: %s", id)
+	}
+
+	f, err := r.GetFilm(ctx, "imdb/tt1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != "imdb-tt1" || f.Provider != film.ProviderImdb {
+		t.Errorf("expected the film to be marked with its provider, got: %+v", f)
+	}
+	if _, err := r.GetFilm(ctx, "letterboxd/matrix"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got: %v", err)
+	}
+
+	legacy, err := r.Refresh(ctx, &film.Item{ID: "42"})
+	if err != nil || legacy.Description != "by kinopoisk" {
+		t.Errorf("expected films without a provider to be refreshed from kinopoisk, got: %+v, %v", legacy, err)
+	}
+
+	manual, err := r.Manual(&film.Item{Title: "Домашнее видео"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(manual.ID, manualPrefix) || manual.Provider != film.ProviderManual {
+		t.Errorf("expected a manual film, got: %+v", manual)
+	}
+	refreshed, err := r.Refresh(ctx, manual)
+	if err != nil || refreshed.Description != "" || refreshed.RefreshedAt.Before(manual.RefreshedAt) {
+		t.Errorf("expected a manual film to be kept as is, got: %+v, %v", refreshed, err)
+	}
+}
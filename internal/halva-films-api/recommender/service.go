@@ -0,0 +1,267 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/halva-films-api/stats"
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	// collaborativeWeight is the share of the taste of similar users in the final score, the rest is content similarity
+	collaborativeWeight = 0.6
+	// significantCommon is the number of common films after which the similarity of two users is fully trusted
+	significantCommon = 5
+	minSimilarity     = 0.1
+	reasonsNumber     = 2
+	// scoreRange is the distance from the worst score to the best one, both parts of the final score are scaled by it
+	scoreRange = float64(film.ExcellentScore - film.BadScore)
+)
+
+var ErrNoScores = errors.New("user has not scored any film yet")
+
+type filmService interface {
+	All(ctx context.Context) (film.Items, error)
+}
+
+type userService interface {
+	Names(ctx context.Context) (map[string]string, error)
+}
+
+type Recommendation struct {
+	Film        film.Item
+	Score       float64
+	Explanation string
+}
+
+type service struct {
+	film filmService
+	user userService
+}
+
+func New(films filmService, users userService) *service {
+	return &service{film: films, user: users}
+}
+
+// Recommend suggests films the user has not scored or dropped, ordered by the predicted score
+func (s *service) Recommend(ctx context.Context, userID string, limit int) ([]Recommendation, error) {
+	all, err := s.film.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get all films")
+	}
+
+	scores := make(map[string]map[string]film.Score)
+	for i := range all {
+		for u, score := range all[i].Scores {
+			if scores[u] == nil {
+				scores[u] = make(map[string]film.Score)
+			}
+			scores[u][all[i].ID] = score
+		}
+	}
+	mine := scores[userID]
+	if len(mine) == 0 {
+		return nil, ErrNoScores
+	}
+
+	names, err := s.user.Names(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user names")
+	}
+
+	neighbours := similarUsers(userID, scores)
+	taste := newProfile(all, mine)
+
+	res := make([]Recommendation, 0, len(all))
+	for i := range all {
+		f := &all[i]
+		if _, ok := mine[f.ID]; ok || f.Statuses[userID] == film.StatusDropped {
+			continue
+		}
+
+		collaborative, fans := neighbours.predict(f, scores)
+		content, features := taste.match(f)
+		// the predicted score is from the worst to the best score and the affinity is the distance from the mean score,
+		// both are scaled to [0, 1] so collaborativeWeight is the real share of the taste of similar users
+		collaborative = (collaborative - float64(film.BadScore)) / scoreRange
+		content = (content + scoreRange) / (2 * scoreRange)
+		res = append(res, Recommendation{
+			Film:        *f,
+			Score:       collaborativeWeight*collaborative + (1-collaborativeWeight)*content,
+			Explanation: explain(fans, features, names),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score == res[j].Score {
+			return res[i].Film.Title < res[j].Film.Title
+		}
+		return res[i].Score > res[j].Score
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// neighbours maps users to the similarity of their taste to the taste of the user
+type neighbours map[string]float64
+
+func similarUsers(userID string, scores map[string]map[string]film.Score) neighbours {
+	res := make(neighbours)
+	for other, theirs := range scores {
+		if other == userID {
+			continue
+		}
+		var xs, ys []float64
+		for filmID, x := range scores[userID] {
+			if y, ok := theirs[filmID]; ok {
+				xs, ys = append(xs, float64(x)), append(ys, float64(y))
+			}
+		}
+		similarity := stats.Pearson(xs, ys) * math.Min(float64(len(xs)), significantCommon) / significantCommon
+		if similarity >= minSimilarity {
+			res[other] = similarity
+		}
+	}
+	return res
+}
+
+// predict averages the scores of similar users who scored the film, those who liked it are returned as fans
+func (n neighbours) predict(f *film.Item, scores map[string]map[string]film.Score) (float64, []string) {
+	var sum, weights float64
+	var fans []string
+	for u, similarity := range n {
+		score, ok := scores[u][f.ID]
+		if !ok {
+			continue
+		}
+		sum += similarity * float64(score)
+		weights += similarity
+		if score >= film.GoodScore {
+			fans = append(fans, u)
+		}
+	}
+	if weights == 0 {
+		return 0, nil
+	}
+	sort.Slice(fans, func(i, j int) bool {
+		return n[fans[i]] > n[fans[j]]
+	})
+	return sum / weights, fans
+}
+
+// profile is how much the user likes genres, directors and decades compared to their average score
+type profile struct {
+	genres    map[string]float64
+	directors map[string]float64
+	decades   map[string]float64
+}
+
+func newProfile(all film.Items, mine map[string]film.Score) *profile {
+	var mean float64
+	for _, v := range mine {
+		mean += float64(v)
+	}
+	mean /= float64(len(mine))
+
+	sums := [3]map[string]float64{{}, {}, {}}
+	counts := [3]map[string]float64{{}, {}, {}}
+	for i := range all {
+		score, ok := mine[all[i].ID]
+		if !ok {
+			continue
+		}
+		for kind, values := range features(&all[i]) {
+			for _, v := range values {
+				sums[kind][v] += float64(score) - mean
+				counts[kind][v]++
+			}
+		}
+	}
+	for kind := range sums {
+		for k := range sums[kind] {
+			sums[kind][k] /= counts[kind][k]
+		}
+	}
+	return &profile{genres: sums[0], directors: sums[1], decades: sums[2]}
+}
+
+// match returns the mean affinity to the features of the film and the features the user likes
+func (p *profile) match(f *film.Item) (float64, []string) {
+	var (
+		sum   float64
+		n     int
+		liked []string
+	)
+	for kind, values := range features(f) {
+		affinities := [3]map[string]float64{p.genres, p.directors, p.decades}[kind]
+		for _, v := range values {
+			affinity, ok := affinities[v]
+			if !ok {
+				continue
+			}
+			sum += affinity
+			n++
+			if affinity > 0 {
+				liked = append(liked, describe(kind, v))
+			}
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return sum / float64(n), liked
+}
+
+func features(f *film.Item) [3][]string {
+	var decade []string
+	if f.Year > 0 {
+		decade = []string{strconv.Itoa(f.Year/10*10) + "s"}
+	}
+	return [3][]string{f.Genres, f.Directors(), decade}
+}
+
+func describe(kind int, value string) string {
+	switch kind {
+	case 0:
+		return "you like " + value
+	case 1:
+		return "you liked other films by " + value
+	default:
+		return "you like films of the " + value
+	}
+}
+
+// explain lists the reasons of the recommendation, fans are shown by their names if they are known
+func explain(fans, liked []string, names map[string]string) string {
+	reasons := make([]string, 0, 1+reasonsNumber)
+	if len(fans) > 0 {
+		if len(fans) > reasonsNumber {
+			fans = fans[:reasonsNumber]
+		}
+		shown := make([]string, 0, len(fans))
+		for _, id := range fans {
+			if name := names[id]; name != "" {
+				id = name
+			}
+			shown = append(shown, id)
+		}
+		reasons = append(reasons, fmt.Sprintf("users with similar taste liked it (%s)", strings.Join(shown, ", ")))
+	}
+	if len(liked) > reasonsNumber {
+		liked = liked[:reasonsNumber]
+	}
+	reasons = append(reasons, liked...)
+	if len(reasons) == 0 {
+		return "nothing is known about it yet, give it a try"
+	}
+	return strings.Join(reasons, "; ")
+}
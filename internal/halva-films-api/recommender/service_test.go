@@ -0,0 +1,61 @@
+package recommender
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeFilms film.Items
+
+func (f fakeFilms) All(context.Context) (film.Items, error) {
+	return film.Items(f), nil
+}
+
+type fakeUsers map[string]string
+
+func (u fakeUsers) Names(context.Context) (map[string]string, error) {
+	return u, nil
+}
+
+func TestService_Recommend(t *testing.T) {
+	s := New(fakeFilms{
+		{ID: "1", Title: "One", Year: 1994, Genres: []string{"драма"}, Director: "A",
+			Scores: map[string]film.Score{"a": 2, "b": 2, "c": -1}},
+		{ID: "2", Title: "Two", Year: 1999, Genres: []string{"драма", "комедия"},
+			Scores: map[string]film.Score{"a": 1, "b": 1, "c": 2}},
+		{ID: "3", Title: "Three", Year: 2005, Genres: []string{"комедия"},
+			Scores: map[string]film.Score{"a": -1, "b": 0, "c": 1}},
+		{ID: "4", Title: "Four", Year: 2010, Genres: []string{"комедия"},
+			Scores: map[string]film.Score{"b": 2, "c": -1}},
+		{ID: "5", Title: "Five", Year: 2020, Genres: []string{"драма"}},
+		{ID: "6", Title: "Six", Year: 1995, Genres: []string{"драма"}, Director: "A",
+			Statuses: map[string]film.Status{"a": film.StatusDropped}},
+	}, fakeUsers{"a": "alice", "b": "bob"})
+
+	res, err := s.Recommend(context.Background(), "a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected only the unscored and not dropped films, got: %+v", res)
+	}
+	if res[0].Film.ID != "4" || !strings.Contains(res[0].Explanation, "(bob)") {
+		t.Errorf("expected film 4 liked by b to be the first, got: %+v", res[0])
+	}
+	if res[1].Film.ID != "5" || res[1].Explanation != "you like драма" {
+		t.Errorf("expected film 5 to be recommended by genre, got: %+v", res[1])
+	}
+	for i := range res {
+		if res[i].Score < 0 || res[i].Score > 1 {
+			t.Errorf("expected a score from 0 to 1, got: %v", res[i].Score)
+		}
+	}
+
+	if _, err := s.Recommend(context.Background(), "d", 0); !errors.Is(err, ErrNoScores) {
+		t.Errorf("expected ErrNoScores, got: %v", err)
+	}
+}
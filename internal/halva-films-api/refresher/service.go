@@ -0,0 +1,122 @@
+package refresher
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
+)
+
+type Config struct {
+	Interval time.Duration `yaml:"interval"` // how often outdated films are looked for, zero disables the refresher
+	MaxAge   time.Duration `yaml:"max_age"`  // films refreshed earlier are outdated
+	Rate     time.Duration `yaml:"rate"`     // pause between two provider requests
+}
+
+type filmService interface {
+	All(ctx context.Context) (film.Items, error)
+	Refresh(ctx context.Context, url string) (*film.Item, error)
+}
+
+type service struct {
+	film filmService
+	cfg  Config
+}
+
+func New(films filmService, cfg Config) *service {
+	return &service{
+		film: films,
+		cfg:  cfg,
+	}
+}
+
+// Start periodically refreshes outdated films until ctx is done
+func (s *service) Start(ctx context.Context) {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+	logger := contexts.GetLogger(ctx)
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := s.RefreshOutdated(ctx)
+				if err != nil {
+					logger.Error("failed to refresh outdated films", zap.Error(err))
+				}
+				if n > 0 {
+					logger.Info("outdated films refreshed", zap.Int("count", n))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// RefreshOutdated refreshes films older than the max age, the oldest first, no more often than the rate allows
+func (s *service) RefreshOutdated(ctx context.Context) (int, error) {
+	outdated, err := s.Outdated(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	logger := contexts.GetLogger(ctx)
+	var limiter <-chan time.Time
+	if s.cfg.Rate > 0 {
+		ticker := time.NewTicker(s.cfg.Rate)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	refreshed := 0
+	for i := range outdated {
+		if i > 0 && limiter != nil {
+			select {
+			case <-limiter:
+			case <-ctx.Done():
+				return refreshed, ctx.Err()
+			}
+		}
+		if _, err := s.film.Refresh(ctx, outdated[i].ID); err != nil {
+			logger.Warn("failed to refresh film", zap.String("id", outdated[i].ID), zap.Error(err))
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// Outdated returns films which were not refreshed for longer than the max age, the oldest first
+func (s *service) Outdated(ctx context.Context, now time.Time) (film.Items, error) {
+	all, err := s.film.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get all films")
+	}
+
+	outdated := make(film.Items, 0, len(all))
+	for i := range all {
+		if now.Sub(refreshedAt(&all[i])) > s.cfg.MaxAge {
+			outdated = append(outdated, all[i])
+		}
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		return refreshedAt(&outdated[i]).Before(refreshedAt(&outdated[j]))
+	})
+	return outdated, nil
+}
+
+// refreshedAt treats films added before refreshing existed as refreshed when they were created
+func refreshedAt(f *film.Item) time.Time {
+	if f.RefreshedAt.IsZero() {
+		return f.CreatedAt
+	}
+	return f.RefreshedAt
+}
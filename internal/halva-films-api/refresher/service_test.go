@@ -0,0 +1,49 @@
+package refresher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeFilms struct {
+	films     film.Items
+	refreshed []string
+}
+
+func (f *fakeFilms) All(context.Context) (film.Items, error) {
+	return f.films, nil
+}
+
+func (f *fakeFilms) Refresh(_ context.Context, id string) (*film.Item, error) {
+	if id == "broken" {
+		return nil, errors.New("kinopoisk is down")
+	}
+	f.refreshed = append(f.refreshed, id)
+	return &film.Item{ID: id}, nil
+}
+
+func TestService_RefreshOutdated(t *testing.T) {
+	now := time.Now()
+	films := &fakeFilms{films: film.Items{
+		{ID: "fresh", CreatedAt: now.Add(-30 * time.Hour), RefreshedAt: now.Add(-time.Hour)},
+		{ID: "old", CreatedAt: now.Add(-72 * time.Hour), RefreshedAt: now.Add(-48 * time.Hour)},
+		{ID: "broken", CreatedAt: now.Add(-40 * time.Hour)},
+		{ID: "never", CreatedAt: now.Add(-96 * time.Hour)},
+	}}
+	s := New(films, Config{MaxAge: 24 * time.Hour, Rate: time.Millisecond})
+
+	n, err := s.RefreshOutdated(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 refreshed films, got: %d", n)
+	}
+	if len(films.refreshed) != 2 || films.refreshed[0] != "never" || films.refreshed[1] != "old" {
+		t.Errorf("expected the oldest films to be refreshed first, got: %v", films.refreshed)
+	}
+}
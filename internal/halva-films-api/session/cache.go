@@ -0,0 +1,45 @@
+package session
+
+import (
+	"time"
+
+	pcache "github.com/patrickmn/go-cache"
+)
+
+type cache struct {
+	*pcache.Cache
+}
+
+func NewCache(defaultExpiration, cleanupInterval time.Duration) *cache {
+	return &cache{
+		Cache: pcache.New(defaultExpiration, cleanupInterval),
+	}
+}
+
+func (c *cache) Set(item *Item) {
+	if item != nil {
+		c.SetDefault(item.ID, *item)
+	}
+}
+
+func (c *cache) Get(id string) (*Item, bool) {
+	v, ok := c.Cache.Get(id)
+	if !ok {
+		return nil, false
+	}
+	if i, ok := v.(Item); ok {
+		return &i, true
+	}
+	return nil, false
+}
+
+func (c *cache) All() []Item {
+	items := c.Items()
+	result := make([]Item, 0, len(items))
+	for _, v := range items {
+		if i, ok := v.Object.(Item); ok {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+type RSVP string
+
+const (
+	RSVPInvited  RSVP = "invited"
+	RSVPGoing    RSVP = "going"
+	RSVPMaybe    RSVP = "maybe"
+	RSVPDeclined RSVP = "declined"
+)
+
+func ValidRSVP(rsvp RSVP) bool {
+	switch rsvp {
+	case RSVPGoing, RSVPMaybe, RSVPDeclined:
+		return true
+	default:
+		return false
+	}
+}
+
+// Item is a planned viewing of the film
+type Item struct {
+	ID        string          `firestore:"-"`
+	FilmID    string          `firestore:"film_id"`
+	CreatedBy string          `firestore:"created_by"`
+	StartsAt  time.Time       `firestore:"starts_at"`
+	Attendees map[string]RSVP `firestore:"attendees"`
+	CreatedAt time.Time       `firestore:"created_at"`
+}
+
+// Going returns attendees who accepted the invitation
+func (i *Item) Going() []string {
+	going := make([]string, 0, len(i.Attendees))
+	for userID, rsvp := range i.Attendees {
+		if rsvp == RSVPGoing {
+			going = append(going, userID)
+		}
+	}
+	return going
+}
+
+func Parse(doc *firestore.DocumentSnapshot) (*Item, error) {
+	var i Item
+	if err := doc.DataTo(&i); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	i.ID = doc.Ref.ID
+	return &i, nil
+}
@@ -0,0 +1,220 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const defaultFilmLength = 2 * time.Hour
+
+var (
+	ErrNotFound  = errors.New("session not found")
+	ErrForbidden = errors.New("session belongs to another user")
+)
+
+type cacheService interface {
+	Set(item *Item)
+	Get(id string) (*Item, bool)
+	Delete(id string)
+	All() []Item
+}
+
+type storageService interface {
+	Create(ctx context.Context, item *Item) error
+	SetAttendee(ctx context.Context, id, userID string, rsvp RSVP) error
+	Delete(ctx context.Context, id string) error
+	All(ctx context.Context) ([]Item, error)
+}
+
+type filmService interface {
+	Find(ctx context.Context, url string) (*film.Item, error)
+	All(ctx context.Context) (film.Items, error)
+}
+
+type service struct {
+	film    filmService
+	cache   cacheService
+	storage storageService
+}
+
+func New(films filmService, cache cacheService, storage storageService) *service {
+	return &service{
+		film:    films,
+		cache:   cache,
+		storage: storage,
+	}
+}
+
+func (s *service) FillCache(ctx context.Context) error {
+	sessions, err := s.storage.All(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get sessions from storage")
+	}
+	for i := range sessions {
+		s.cache.Set(&sessions[i])
+	}
+	return nil
+}
+
+// Create plans a viewing of the film, the creator is going and the other attendees are invited
+func (s *service) Create(ctx context.Context, userID, filmURL string, startsAt time.Time, attendees []string) (*Item, error) {
+	f, err := s.film.Find(ctx, filmURL)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &Item{
+		FilmID:    f.ID,
+		CreatedBy: userID,
+		StartsAt:  startsAt,
+		Attendees: make(map[string]RSVP, len(attendees)+1),
+		CreatedAt: time.Now(),
+	}
+	for i := range attendees {
+		item.Attendees[attendees[i]] = RSVPInvited
+	}
+	item.Attendees[userID] = RSVPGoing
+
+	if err := s.storage.Create(ctx, item); err != nil {
+		return nil, errors.Wrap(err, "create session in storage")
+	}
+	s.cache.Set(item)
+	return item, nil
+}
+
+func (s *service) Get(id string) (*Item, error) {
+	item, ok := s.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// All returns every session, the latest first
+func (s *service) All() []Item {
+	sessions := s.cache.All()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartsAt.After(sessions[j].StartsAt)
+	})
+	return sessions
+}
+
+// RSVP answers the invitation, users who were not invited join the session
+func (s *service) RSVP(ctx context.Context, id, userID string, rsvp RSVP) (*Item, error) {
+	item, ok := s.cache.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if err := s.storage.SetAttendee(ctx, id, userID, rsvp); err != nil {
+		return nil, errors.Wrap(err, "set attendee in storage")
+	}
+	attendees := make(map[string]RSVP, len(item.Attendees)+1)
+	for k, v := range item.Attendees {
+		attendees[k] = v
+	}
+	attendees[userID] = rsvp
+	item.Attendees = attendees
+
+	s.cache.Set(item)
+	return item, nil
+}
+
+// Delete cancels the session, only its creator can do it unless force is set
+func (s *service) Delete(ctx context.Context, id, userID string, force bool) error {
+	item, ok := s.cache.Get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if !force && item.CreatedBy != userID {
+		return ErrForbidden
+	}
+
+	if err := s.storage.Delete(ctx, id); err != nil {
+		return errors.Wrap(err, "delete session from storage")
+	}
+	s.cache.Delete(id)
+	return nil
+}
+
+// Unscored returns attendees who went to the finished sessions but have not scored the film yet, by session IDs
+func (s *service) Unscored(ctx context.Context, items []Item) (map[string][]string, error) {
+	films, err := s.films(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := make(map[string][]string, len(items))
+	for i := range items {
+		if users := unscored(&items[i], films[items[i].FilmID], now); len(users) > 0 {
+			res[items[i].ID] = users
+		}
+	}
+	return res, nil
+}
+
+// Reminders returns finished sessions the user went to without scoring the film afterwards
+func (s *service) Reminders(ctx context.Context, userID string) ([]Item, error) {
+	films, err := s.films(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	all := s.All()
+	reminders := make([]Item, 0, len(all))
+	for i := range all {
+		if all[i].Attendees[userID] != RSVPGoing {
+			continue
+		}
+		for _, id := range unscored(&all[i], films[all[i].FilmID], now) {
+			if id == userID {
+				reminders = append(reminders, all[i])
+				break
+			}
+		}
+	}
+	return reminders, nil
+}
+
+// films returns the cached films by ID, their comments are not loaded
+func (s *service) films(ctx context.Context) (map[string]*film.Item, error) {
+	all, err := s.film.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get all films")
+	}
+	films := make(map[string]*film.Item, len(all))
+	for i := range all {
+		films[all[i].ID] = &all[i]
+	}
+	return films, nil
+}
+
+// unscored returns sorted attendees going to the session without the score of the film, the film is nil if it is deleted
+func unscored(item *Item, f *film.Item, now time.Time) []string {
+	if f == nil || !ended(item, f, now) {
+		return nil
+	}
+	going := item.Going()
+	res := make([]string, 0, len(going))
+	for _, userID := range going {
+		if _, ok := f.Scores[userID]; !ok {
+			res = append(res, userID)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func ended(item *Item, f *film.Item, now time.Time) bool {
+	length := defaultFilmLength
+	if f.FilmLength > 0 {
+		length = time.Duration(f.FilmLength) * time.Minute
+	}
+	return item.StartsAt.Add(length).Before(now)
+}
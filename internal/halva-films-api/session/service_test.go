@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeFilms struct {
+	films film.Items
+	finds int
+	alls  int
+}
+
+func (f *fakeFilms) Find(_ context.Context, url string) (*film.Item, error) {
+	f.finds++
+	for i := range f.films {
+		if f.films[i].ID == url {
+			return &f.films[i], nil
+		}
+	}
+	return nil, errors.New("film not found")
+}
+
+func (f *fakeFilms) All(context.Context) (film.Items, error) {
+	f.alls++
+	return f.films, nil
+}
+
+type fakeStorage struct {
+	created int
+}
+
+func (s *fakeStorage) Create(_ context.Context, item *Item) error {
+	s.created++
+	item.ID = strconv.Itoa(s.created)
+	return nil
+}
+
+func (s *fakeStorage) SetAttendee(context.Context, string, string, RSVP) error { return nil }
+func (s *fakeStorage) Delete(context.Context, string) error                    { return nil }
+func (s *fakeStorage) All(context.Context) ([]Item, error)                     { return nil, nil }
+
+func newTestService() (*service, *fakeFilms) {
+	films := &fakeFilms{films: film.Items{
+		{ID: "1", FilmLength: 90, Scores: map[string]film.Score{"a": film.GoodScore}},
+		{ID: "2"},
+	}}
+	return New(films, NewCache(time.Hour, time.Hour), &fakeStorage{}), films
+}
+
+func TestService_Create(t *testing.T) {
+	s, _ := newTestService()
+	startsAt := time.Now().Add(time.Hour)
+
+	item, err := s.Create(context.Background(), "a", "1", startsAt, []string{"b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ID == "" || item.FilmID != "1" || item.CreatedBy != "a" || !item.StartsAt.Equal(startsAt) {
+		t.Errorf("unexpected session: %+v", item)
+	}
+	if item.Attendees["a"] != RSVPGoing || item.Attendees["b"] != RSVPInvited || len(item.Attendees) != 2 {
+		t.Errorf("expected the creator to go and the others to be invited, got: %v", item.Attendees)
+	}
+	if got, err := s.Get(item.ID); err != nil || got.FilmID != "1" {
+		t.Errorf("expected the session to be cached, got: %+v, %v", got, err)
+	}
+
+	if _, err := s.Create(context.Background(), "a", "3", startsAt, nil); err == nil {
+		t.Error("expected an error for an unknown film")
+	}
+}
+
+func TestService_All(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+	now := time.Now()
+	for i, startsAt := range []time.Time{now, now.Add(time.Hour), now.Add(-time.Hour)} {
+		if _, err := s.Create(ctx, "a", strconv.Itoa(i%2+1), startsAt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := s.All()
+	if len(all) != 3 {
+		t.Fatalf("expected 3 sessions, got: %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].StartsAt.After(all[i-1].StartsAt) {
+			t.Errorf("expected the latest session first, got: %v before %v", all[i-1].StartsAt, all[i].StartsAt)
+		}
+	}
+}
+
+func TestService_Unscored(t *testing.T) {
+	s, films := newTestService()
+	ctx := context.Background()
+
+	finished, err := s.Create(ctx, "a", "1", time.Now().Add(-2*time.Hour), []string{"b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finished, err = s.RSVP(ctx, finished.ID, "c", RSVPGoing); err != nil {
+		t.Fatal(err)
+	}
+	// the film is 90 minutes long, so it is still on
+	running, err := s.Create(ctx, "b", "1", time.Now().Add(-time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finds := films.finds
+
+	unscored, err := s.Unscored(ctx, []Item{*finished, *running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unscored[finished.ID]; len(got) != 1 || got[0] != "c" {
+		t.Errorf("expected only c who went without a score, got: %v", got)
+	}
+	if got := unscored[running.ID]; len(got) != 0 {
+		t.Errorf("expected no one until the film ends, got: %v", got)
+	}
+	if films.alls != 1 {
+		t.Errorf("expected the films to be loaded once for all sessions, got: %d", films.alls)
+	}
+
+	reminders, err := s.Reminders(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].ID != finished.ID {
+		t.Errorf("expected a reminder of the finished session, got: %+v", reminders)
+	}
+	if reminders, _ = s.Reminders(ctx, "a"); len(reminders) != 0 {
+		t.Errorf("expected no reminders for the one who scored, got: %+v", reminders)
+	}
+	if films.finds != finds {
+		t.Errorf("expected the films to be read from the cache, got %d film loads", films.finds-finds)
+	}
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
+)
+
+const approximateSessionsNumber = 32
+
+type storage struct {
+	*firestore.Client
+}
+
+func NewStorage(client *firestore.Client) *storage {
+	return &storage{
+		Client: client,
+	}
+}
+
+// Create saves the new session and sets its generated ID
+func (s *storage) Create(ctx context.Context, item *Item) error {
+	ref := s.Collection(fire.WatchesCollection).NewDoc()
+	if _, err := ref.Create(ctx, item); err != nil {
+		return errors.Wrap(err, "create session doc")
+	}
+	item.ID = ref.ID
+	return nil
+}
+
+// SetAttendee updates a single attendee so concurrent RSVPs do not overwrite each other
+func (s *storage) SetAttendee(ctx context.Context, id, userID string, rsvp RSVP) error {
+	_, err := s.Collection(fire.WatchesCollection).Doc(id).Update(ctx, []firestore.Update{
+		{FieldPath: firestore.FieldPath{"attendees", userID}, Value: rsvp},
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "update session attendee")
+}
+
+func (s *storage) Delete(ctx context.Context, id string) error {
+	_, err := s.Collection(fire.WatchesCollection).Doc(id).Delete(ctx)
+	return errors.Wrap(err, "delete session doc")
+}
+
+func (s *storage) All(ctx context.Context) ([]Item, error) {
+	sessions := make([]Item, 0, approximateSessionsNumber)
+	iter := s.Collection(fire.WatchesCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "get next iterator")
+		}
+		i, err := Parse(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse session doc")
+		}
+		sessions = append(sessions, *i)
+	}
+	return sessions, nil
+}
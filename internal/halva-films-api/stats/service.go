@@ -0,0 +1,253 @@
+package stats
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	favouritesNumber = 5
+	// minFavouriteFilms keeps a single lucky film from making a favourite genre or director
+	minFavouriteFilms = 2
+)
+
+type filmService interface {
+	All(ctx context.Context) (film.Items, error)
+}
+
+type User struct {
+	UserID       string
+	Scored       int
+	Average      float64
+	Distribution map[film.Score]int
+	Genres       []Favourite
+	Directors    []Favourite
+	Decades      []Favourite
+}
+
+// Favourite is a genre, director or decade with the user's average score of its films
+type Favourite struct {
+	Name    string
+	Films   int
+	Average float64
+}
+
+// Pair tells how similar the scores of two users are on the films both of them scored.
+// Agreement is the share of equal scores, Correlation is the Pearson coefficient.
+type Pair struct {
+	UserA       string
+	UserB       string
+	Common      int
+	Agreement   float64
+	Correlation float64
+}
+
+type Controversial struct {
+	Film     film.Item
+	Variance float64
+}
+
+type Report struct {
+	Users         []User
+	Pairs         []Pair
+	Controversial []Controversial
+}
+
+type service struct {
+	film filmService
+}
+
+func New(films filmService) *service {
+	return &service{film: films}
+}
+
+// Report computes the statistics of every user, every pair of users and the most controversial films
+func (s *service) Report(ctx context.Context, controversial int) (*Report, error) {
+	all, err := s.film.All(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get all films")
+	}
+
+	scores := userScores(all)
+	users := make([]string, 0, len(scores))
+	for userID := range scores {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+
+	report := &Report{
+		Users:         make([]User, 0, len(users)),
+		Pairs:         make([]Pair, 0, len(users)*(len(users)-1)/2),
+		Controversial: controversialFilms(all, controversial),
+	}
+	for i := range users {
+		report.Users = append(report.Users, userStats(users[i], all))
+		for j := i + 1; j < len(users); j++ {
+			report.Pairs = append(report.Pairs, pair(users[i], users[j], scores))
+		}
+	}
+	return report, nil
+}
+
+func userScores(all film.Items) map[string]map[string]film.Score {
+	scores := make(map[string]map[string]film.Score)
+	for i := range all {
+		for userID, score := range all[i].Scores {
+			if scores[userID] == nil {
+				scores[userID] = make(map[string]film.Score)
+			}
+			scores[userID][all[i].ID] = score
+		}
+	}
+	return scores
+}
+
+func userStats(userID string, all film.Items) User {
+	u := User{
+		UserID:       userID,
+		Distribution: make(map[film.Score]int, 4),
+	}
+	genres, directors, decades := make(groups), make(groups), make(groups)
+	var sum float64
+	for i := range all {
+		f := &all[i]
+		score, ok := f.Scores[userID]
+		if !ok {
+			continue
+		}
+		u.Scored++
+		u.Distribution[score]++
+		sum += float64(score)
+		for _, g := range f.Genres {
+			genres.add(g, score)
+		}
+		for _, d := range f.Directors() {
+			directors.add(d, score)
+		}
+		if f.Year > 0 {
+			decades.add(strconv.Itoa(f.Year/10*10)+"s", score)
+		}
+	}
+	if u.Scored > 0 {
+		u.Average = sum / float64(u.Scored)
+	}
+	u.Genres, u.Directors, u.Decades = genres.favourites(), directors.favourites(), decades.favourites()
+	return u
+}
+
+type groups map[string]*Favourite
+
+func (g groups) add(name string, score film.Score) {
+	f, ok := g[name]
+	if !ok {
+		f = &Favourite{Name: name}
+		g[name] = f
+	}
+	// Average keeps the sum until favourites are computed
+	f.Films++
+	f.Average += float64(score)
+}
+
+func (g groups) favourites() []Favourite {
+	res := make([]Favourite, 0, len(g))
+	for _, f := range g {
+		if f.Films < minFavouriteFilms {
+			continue
+		}
+		res = append(res, Favourite{Name: f.Name, Films: f.Films, Average: f.Average / float64(f.Films)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Average != res[j].Average {
+			return res[i].Average > res[j].Average
+		}
+		if res[i].Films != res[j].Films {
+			return res[i].Films > res[j].Films
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > favouritesNumber {
+		res = res[:favouritesNumber]
+	}
+	return res
+}
+
+func pair(a, b string, scores map[string]map[string]film.Score) Pair {
+	p := Pair{UserA: a, UserB: b}
+	var xs, ys []float64
+	for filmID, x := range scores[a] {
+		y, ok := scores[b][filmID]
+		if !ok {
+			continue
+		}
+		p.Common++
+		if x == y {
+			p.Agreement++
+		}
+		xs, ys = append(xs, float64(x)), append(ys, float64(y))
+	}
+	if p.Common > 0 {
+		p.Agreement /= float64(p.Common)
+	}
+	p.Correlation = Pearson(xs, ys)
+	return p
+}
+
+// Pearson is the correlation coefficient of the samples, 0 when it is undefined
+func Pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	mx, my := sx/n, sy/n
+
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+func controversialFilms(all film.Items, limit int) []Controversial {
+	res := make([]Controversial, 0, len(all))
+	for i := range all {
+		if len(all[i].Scores) < 2 {
+			continue
+		}
+		mean := float64(all[i].Average())
+		var variance float64
+		for _, v := range all[i].Scores {
+			variance += (float64(v) - mean) * (float64(v) - mean)
+		}
+		variance /= float64(len(all[i].Scores))
+		if variance > 0 {
+			res = append(res, Controversial{Film: all[i], Variance: variance})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Variance == res[j].Variance {
+			return res[i].Film.Title < res[j].Film.Title
+		}
+		return res[i].Variance > res[j].Variance
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+type fakeFilms film.Items
+
+func (f fakeFilms) All(context.Context) (film.Items, error) {
+	return film.Items(f), nil
+}
+
+func TestService_Report(t *testing.T) {
+	s := New(fakeFilms{
+		{ID: "1", Title: "One", Year: 1994, Genres: []string{"драма"}, Director: "A, B",
+			Scores: map[string]film.Score{"a": 2, "b": 2, "c": -1}},
+		{ID: "2", Title: "Two", Year: 1999, Genres: []string{"драма", "комедия"}, Director: "A",
+			Scores: map[string]film.Score{"a": 1, "b": 1, "c": 2}},
+		{ID: "3", Title: "Three", Year: 2005, Genres: []string{"комедия"},
+			Scores: map[string]film.Score{"a": -1, "b": 0, "c": 1}},
+	})
+
+	report, err := s.Report(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := report.Users[0]
+	if a.UserID != "a" || a.Scored != 3 || a.Distribution[2] != 1 {
+		t.Errorf("unexpected stats of a: %+v", a)
+	}
+	if len(a.Genres) != 2 || a.Genres[0].Name != "драма" || a.Genres[0].Average != 1.5 {
+		t.Errorf("expected drama to be the favourite genre of a, got: %+v", a.Genres)
+	}
+	if len(a.Directors) != 1 || a.Directors[0].Name != "A" {
+		t.Errorf("expected only A to have enough films, got: %+v", a.Directors)
+	}
+	if len(a.Decades) != 1 || a.Decades[0].Name != "1990s" {
+		t.Errorf("expected the 1990s, got: %+v", a.Decades)
+	}
+
+	if len(report.Pairs) != 3 {
+		t.Fatalf("expected 3 pairs, got: %d", len(report.Pairs))
+	}
+	ab := report.Pairs[0]
+	if ab.UserA != "a" || ab.UserB != "b" || ab.Common != 3 || math.Abs(ab.Agreement-2.0/3) > 1e-9 || ab.Correlation < 0.9 {
+		t.Errorf("a and b should agree, got: %+v", ab)
+	}
+	if ac := report.Pairs[1]; ac.Correlation >= 0 {
+		t.Errorf("a and c should disagree, got: %+v", ac)
+	}
+
+	if len(report.Controversial) != 1 || report.Controversial[0].Film.ID != "1" {
+		t.Errorf("expected film 1 to be the most controversial, got: %+v", report.Controversial)
+	}
+}
@@ -0,0 +1,235 @@
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/film"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	apiURL      = "https://api.themoviedb.org/3/"
+	siteURL     = "https://www.themoviedb.org/"
+	imagesURL   = "https://image.tmdb.org/t/p/original"
+	idPrefix    = "tmdb-"
+	kindTV      = "tv"
+	language    = "ru-RU"
+	jobDirector = "Director"
+)
+
+var (
+	ErrNotFound = errors.New("film not found on tmdb")
+
+	urlRe = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.)?themoviedb\.org/)?(movie|tv)/(\d+)`)
+)
+
+type tmdb struct {
+	apiKey string
+	client *http.Client
+}
+
+// New creates the provider, requests time out after 10 seconds if the timeout is 0
+func New(apiKey string, timeout time.Duration) *tmdb {
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &tmdb{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (t *tmdb) Name() string {
+	return film.ProviderTmdb
+}
+
+// Match returns the film ID of a tmdb link or of an ID like movie/603, movies and tv shows have separate ID spaces
+func (t *tmdb) Match(url string) (string, bool) {
+	m := urlRe.FindStringSubmatch(strings.TrimSpace(url))
+	if m == nil {
+		return "", false
+	}
+	return idPrefix + m[1] + "-" + m[2], true
+}
+
+func (t *tmdb) GetFilm(ctx context.Context, url string) (*film.Item, error) {
+	m := urlRe.FindStringSubmatch(strings.TrimSpace(url))
+	if m == nil {
+		return nil, ErrNotFound
+	}
+	resp, err := t.getFilm(ctx, m[1], m[2])
+	if err != nil {
+		return nil, err
+	}
+	return buildFilm(m[1], resp), nil
+}
+
+// Refresh fills the missing fields of the copy of the film
+func (t *tmdb) Refresh(ctx context.Context, f *film.Item) (*film.Item, error) {
+	kind, id, _ := strings.Cut(f.TmdbID, "/")
+	resp, err := t.getFilm(ctx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	fresh := buildFilm(kind, resp)
+
+	merged := *f
+	if merged.Title == "" {
+		merged.Title = fresh.Title
+	}
+	if merged.TitleOriginal == "" {
+		merged.TitleOriginal = fresh.TitleOriginal
+	}
+	if merged.Poster == "" {
+		merged.Poster = fresh.Poster
+	}
+	if merged.Cover == "" {
+		merged.Cover = fresh.Cover
+	}
+	if merged.Director == "" {
+		merged.Director = fresh.Director
+	}
+	if merged.Description == "" {
+		merged.Description = fresh.Description
+	}
+	if merged.ImdbID == "" {
+		merged.ImdbID = fresh.ImdbID
+	}
+	if merged.Year == 0 {
+		merged.Year = fresh.Year
+	}
+	if merged.FilmLength == 0 {
+		merged.FilmLength = fresh.FilmLength
+	}
+	if len(merged.Genres) == 0 {
+		merged.Genres = fresh.Genres
+	}
+	merged.RefreshedAt = time.Now()
+	return &merged, nil
+}
+
+func (t *tmdb) getFilm(ctx context.Context, kind, id string) (*filmResp, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+kind+"/"+id, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request get film from tmdb")
+	}
+	q := req.URL.Query()
+	q.Add("api_key", t.apiKey)
+	q.Add("language", language)
+	q.Add("append_to_response", "credits,external_ids")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do http request")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("status not ok: " + string(data))
+	}
+
+	var f filmResp
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrap(err, "unmarshall film")
+	}
+	return &f, nil
+}
+
+func buildFilm(kind string, f *filmResp) *film.Item {
+	item := &film.Item{
+		ID:            idPrefix + kind + "-" + strconv.Itoa(f.ID),
+		Title:         f.Title,
+		TitleOriginal: f.OriginalTitle,
+		Description:   f.Overview,
+		URL:           siteURL + kind + "/" + strconv.Itoa(f.ID),
+		Provider:      film.ProviderTmdb,
+		TmdbID:        kind + "/" + strconv.Itoa(f.ID),
+		ImdbID:        f.ImdbID,
+		FilmLength:    f.Runtime,
+		Serial:        kind == kindTV,
+	}
+	if f.PosterPath != "" {
+		item.Poster = imagesURL + f.PosterPath
+	}
+	if f.BackdropPath != "" {
+		item.Cover = imagesURL + f.BackdropPath
+	}
+	if item.ImdbID == "" {
+		item.ImdbID = f.ExternalIDs.ImdbID
+	}
+
+	date := f.ReleaseDate
+	var directors []string
+	for i := range f.Credits.Crew {
+		if f.Credits.Crew[i].Job == jobDirector {
+			directors = append(directors, f.Credits.Crew[i].Name)
+		}
+	}
+	if kind == kindTV {
+		item.Title, item.TitleOriginal, date = f.Name, f.OriginalName, f.FirstAirDate
+		if len(f.EpisodeRunTime) > 0 {
+			item.FilmLength = f.EpisodeRunTime[0]
+		}
+		for i := range f.CreatedBy {
+			directors = append(directors, f.CreatedBy[i].Name)
+		}
+	}
+	item.Director = strings.Join(directors, ", ")
+	if len(date) >= 4 {
+		item.Year, _ = strconv.Atoi(date[:4])
+	}
+	for i := range f.Genres {
+		item.Genres = append(item.Genres, strings.ToLower(f.Genres[i].Name))
+	}
+	return item
+}
+
+type filmResp struct {
+	ID             int     `json:"id"`
+	Title          string  `json:"title"`
+	Name           string  `json:"name"`
+	OriginalTitle  string  `json:"original_title"`
+	OriginalName   string  `json:"original_name"`
+	Overview       string  `json:"overview"`
+	PosterPath     string  `json:"poster_path"`
+	BackdropPath   string  `json:"backdrop_path"`
+	ReleaseDate    string  `json:"release_date"`
+	FirstAirDate   string  `json:"first_air_date"`
+	Runtime        int     `json:"runtime"`
+	EpisodeRunTime []int   `json:"episode_run_time"`
+	Genres         []genre `json:"genres"`
+	ImdbID         string  `json:"imdb_id"`
+	ExternalIDs    struct {
+		ImdbID string `json:"imdb_id"`
+	} `json:"external_ids"`
+	Credits struct {
+		Crew []person `json:"crew"`
+	} `json:"credits"`
+	CreatedBy []person `json:"created_by"`
+}
+
+type genre struct {
+	Name string `json:"name"`
+}
+
+type person struct {
+	Name string `json:"name"`
+	Job  string `json:"job"`
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type storageService interface {
+	Names(ctx context.Context) (map[string]string, error)
+}
+
+// service shows the names of users, the users are managed by the auth api so the names are only read
+type service struct {
+	storage storageService
+	ttl     time.Duration
+
+	mx     sync.Mutex
+	names  map[string]string
+	loaded time.Time
+}
+
+// New creates the service, the names are read again after the ttl so new users are shown by their names
+func New(storage storageService, ttl time.Duration) *service {
+	return &service{
+		storage: storage,
+		ttl:     ttl,
+	}
+}
+
+// Names returns the names of all users, userID -> name
+func (s *service) Names(ctx context.Context) (map[string]string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.names != nil && time.Since(s.loaded) < s.ttl {
+		return s.names, nil
+	}
+	names, err := s.storage.Names(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user names from storage")
+	}
+	s.names, s.loaded = names, time.Now()
+	return names, nil
+}
@@ -0,0 +1,47 @@
+package user
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+
+	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
+)
+
+const approximateUsersNumber = 10
+
+type storage struct {
+	*firestore.Client
+}
+
+func NewStorage(client *firestore.Client) *storage {
+	return &storage{
+		Client: client,
+	}
+}
+
+// Names reads only the names of the users, userID -> name
+func (s *storage) Names(ctx context.Context) (map[string]string, error) {
+	names := make(map[string]string, approximateUsersNumber)
+	iter := s.Collection(fire.UsersCollection).Select("username").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "get next iterator")
+		}
+		var u struct {
+			Username string `firestore:"username"`
+		}
+		if err := doc.DataTo(&u); err != nil {
+			return nil, errors.Wrap(err, "parse user doc")
+		}
+		names[doc.Ref.ID] = u.Username
+	}
+	return names, nil
+}
@@ -1,6 +1,7 @@
 package film
 
 import (
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -16,38 +17,147 @@ const (
 	ExcellentScore       = 2
 )
 
+// Status is where the film is in the user's watchlist, it is kept apart from scores
+type Status string
+
+const (
+	StatusWant     Status = "want"
+	StatusWatching Status = "watching"
+	StatusWatched  Status = "watched"
+	StatusDropped  Status = "dropped"
+)
+
+func ValidStatus(status Status) bool {
+	switch status {
+	case StatusWant, StatusWatching, StatusWatched, StatusDropped:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	ProviderKinopoisk = "kinopoisk"
+	ProviderImdb      = "imdb"
+	ProviderTmdb      = "tmdb"
+	ProviderManual    = "manual"
+)
+
 // Item TODO: user tags
 type Item struct {
-	ID                       string           `firestore:"-" json:"id"`
-	Title                    string           `firestore:"title,omitempty" json:"title"`
-	TitleOriginal            string           `firestore:"title_original,omitempty" json:"title_original,omitempty"`
-	Poster                   string           `firestore:"cover,omitempty" json:"cover,omitempty"`
-	Cover                    string           `firestore:"poster,omitempty" json:"poster,omitempty"`
-	Director                 string           `firestore:"director,omitempty" json:"director,omitempty"`
-	Description              string           `firestore:"description,omitempty" json:"description,omitempty"`
-	ShortDescription         string           `firestore:"short_description,omitempty" json:"short_description,omitempty"`
-	Duration                 string           `firestore:"duration,omitempty" json:"duration,omitempty"`
-	Scores                   map[string]Score `firestore:"scores" json:"scores,omitempty"`
-	Comments                 []Comment        `firestore:"-" json:"comments,omitempty"`
-	NoComments               bool             `firestore:"-" json:"-"`
-	URL                      string           `firestore:"kinopoisk,omitempty" json:"kinopoisk,omitempty"`
-	RatingKinopoisk          float64          `firestore:"rating_kinopoisk,omitempty" json:"rating_kinopoisk,omitempty"`
-	RatingKinopoiskVoteCount int              `firestore:"rating_kinopoisk_vote_count,omitempty" json:"rating_kinopoisk_vote_count,omitempty"`
-	RatingImdb               float64          `firestore:"rating_imdb,omitempty" json:"rating_imdb,omitempty"`
-	RatingImdbVoteCount      int              `firestore:"rating_imdb_vote_count,omitempty" json:"rating_imdb_vote_count,omitempty"`
-	Year                     int              `firestore:"year,omitempty" json:"year,omitempty"`
-	FilmLength               int              `firestore:"film_length,omitempty" json:"film_length,omitempty"`
-	Serial                   bool             `firestore:"serial" json:"serial"`
-	ShortFilm                bool             `firestore:"short_film" json:"short_film"`
-	Genres                   []string         `firestore:"genres,omitempty" json:"genres,omitempty"`
-	UpdatedAt                time.Time        `firestore:"updated_at,omitempty" json:"updated_at,omitempty"`
-	CreatedAt                time.Time        `firestore:"created_at,omitempty" json:"created_at,omitempty"`
+	ID                       string            `firestore:"-" json:"id"`
+	Title                    string            `firestore:"title,omitempty" json:"title"`
+	TitleOriginal            string            `firestore:"title_original,omitempty" json:"title_original,omitempty"`
+	Poster                   string            `firestore:"cover,omitempty" json:"cover,omitempty"`
+	Cover                    string            `firestore:"poster,omitempty" json:"poster,omitempty"`
+	Director                 string            `firestore:"director,omitempty" json:"director,omitempty"`
+	Description              string            `firestore:"description,omitempty" json:"description,omitempty"`
+	ShortDescription         string            `firestore:"short_description,omitempty" json:"short_description,omitempty"`
+	Duration                 string            `firestore:"duration,omitempty" json:"duration,omitempty"`
+	Scores                   map[string]Score  `firestore:"scores" json:"scores,omitempty"`
+	Statuses                 map[string]Status `firestore:"statuses,omitempty" json:"statuses,omitempty"`
+	AddedBy                  string            `firestore:"added_by,omitempty" json:"added_by,omitempty"`
+	Comments                 []Comment         `firestore:"-" json:"comments,omitempty"`
+	NoComments               bool              `firestore:"-" json:"-"`
+	CommentTimes             CommentTimes      `firestore:"comment_times,omitempty" json:"-"`
+	URL                      string            `firestore:"kinopoisk,omitempty" json:"kinopoisk,omitempty"` // page of the film on the provider site
+	Provider                 string            `firestore:"provider,omitempty" json:"provider,omitempty"`
+	KinopoiskID              string            `firestore:"kinopoisk_id,omitempty" json:"kinopoisk_id,omitempty"`
+	ImdbID                   string            `firestore:"imdb_id,omitempty" json:"imdb_id,omitempty"`
+	TmdbID                   string            `firestore:"tmdb_id,omitempty" json:"tmdb_id,omitempty"`
+	RatingKinopoisk          float64           `firestore:"rating_kinopoisk,omitempty" json:"rating_kinopoisk,omitempty"`
+	RatingKinopoiskVoteCount int               `firestore:"rating_kinopoisk_vote_count,omitempty" json:"rating_kinopoisk_vote_count,omitempty"`
+	RatingImdb               float64           `firestore:"rating_imdb,omitempty" json:"rating_imdb,omitempty"`
+	RatingImdbVoteCount      int               `firestore:"rating_imdb_vote_count,omitempty" json:"rating_imdb_vote_count,omitempty"`
+	Year                     int               `firestore:"year,omitempty" json:"year,omitempty"`
+	FilmLength               int               `firestore:"film_length,omitempty" json:"film_length,omitempty"`
+	Serial                   bool              `firestore:"serial" json:"serial"`
+	ShortFilm                bool              `firestore:"short_film" json:"short_film"`
+	Genres                   []string          `firestore:"genres,omitempty" json:"genres,omitempty"`
+	UpdatedAt                time.Time         `firestore:"updated_at,omitempty" json:"updated_at,omitempty"`
+	RefreshedAt              time.Time         `firestore:"refreshed_at,omitempty" json:"refreshed_at,omitempty"`
+	CreatedAt                time.Time         `firestore:"created_at,omitempty" json:"created_at,omitempty"`
 }
 
 type Comment struct {
-	UserID    string    `firestore:"user_id" json:"user_id"`
-	Text      string    `firestore:"text" json:"text"`
-	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+	ID        string              `firestore:"-" json:"id"`
+	ParentID  string              `firestore:"parent_id,omitempty" json:"parent_id,omitempty"`
+	UserID    string              `firestore:"user_id" json:"user_id"`
+	Text      string              `firestore:"text" json:"text"`
+	Reactions map[string][]string `firestore:"reactions,omitempty" json:"reactions,omitempty"` // emoji -> users
+	Deleted   bool                `firestore:"deleted,omitempty" json:"deleted,omitempty"`     // kept for the replies
+	Spoiler   bool                `firestore:"spoiler,omitempty" json:"spoiler,omitempty"`
+	CreatedAt time.Time           `firestore:"created_at" json:"created_at"`
+	EditedAt  time.Time           `firestore:"edited_at,omitempty" json:"edited_at,omitempty"`
+}
+
+// CommentTimes keep the author and the time of every comment that is not deleted, commentID -> time,
+// so unread comments are counted without loading them
+type CommentTimes map[string]CommentTime
+
+type CommentTime struct {
+	UserID    string    `firestore:"user_id"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// React adds the reaction of the user or takes it back if it is already there
+func (c *Comment) React(userID, emoji string) {
+	users := c.Reactions[emoji]
+	for i := range users {
+		if users[i] == userID {
+			users = append(users[:i:i], users[i+1:]...)
+			if len(users) == 0 {
+				delete(c.Reactions, emoji)
+			} else {
+				c.Reactions[emoji] = users
+			}
+			return
+		}
+	}
+	if c.Reactions == nil {
+		c.Reactions = make(map[string][]string)
+	}
+	c.Reactions[emoji] = append(users, userID)
+}
+
+// Watched reports whether the user has scored or watched the film, so spoilers can be shown to them
+func (f *Item) Watched(userID string) bool {
+	if _, ok := f.Scores[userID]; ok {
+		return true
+	}
+	return f.Statuses[userID] == StatusWatched
+}
+
+// SameAs reports whether both items are the same film known under different IDs or added from different sites
+func (f *Item) SameAs(other *Item) bool {
+	switch {
+	case f.KinopoiskID != "" && f.KinopoiskID == other.KinopoiskID,
+		f.ImdbID != "" && f.ImdbID == other.ImdbID,
+		f.TmdbID != "" && f.TmdbID == other.TmdbID:
+		return true
+	case f.Year == 0 || f.Year != other.Year:
+		return false
+	}
+	for _, a := range [2]string{f.Title, f.TitleOriginal} {
+		for _, b := range [2]string{other.Title, other.TitleOriginal} {
+			if a != "" && strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Directors splits the comma separated directors of the film
+func (f *Item) Directors() []string {
+	if f.Director == "" {
+		return nil
+	}
+	directors := strings.Split(f.Director, ",")
+	for i := range directors {
+		directors[i] = strings.TrimSpace(directors[i])
+	}
+	return directors
 }
 
 func Parse(doc *firestore.DocumentSnapshot) (*Item, error) {
@@ -64,5 +174,6 @@ func ParseComment(doc *firestore.DocumentSnapshot) (*Comment, error) {
 	if err := doc.DataTo(&c); err != nil {
 		return nil, errors.Wrap(err, "unmarshall data")
 	}
+	c.ID = doc.Ref.ID
 	return &c, nil
 }
@@ -0,0 +1,41 @@
+package film
+
+import "testing"
+
+func TestItem_SameAs(t *testing.T) {
+	kp := &Item{ID: "301", KinopoiskID: "301", ImdbID: "tt0133093", Title: "Матрица", TitleOriginal: "The Matrix", Year: 1999}
+	testCases := []struct {
+		name  string
+		other *Item
+		same  bool
+	}{
+		{"same imdb id", &Item{ID: "imdb-tt0133093", ImdbID: "tt0133093"}, true},
+		{"original title and year", &Item{ID: "tmdb-movie-603", Title: "the matrix ", Year: 1999}, true},
+		{"remake", &Item{ID: "manual-1", Title: "The Matrix", Year: 2031}, false},
+		{"other film", &Item{ID: "imdb-tt0234215", ImdbID: "tt0234215", Title: "The Matrix Reloaded", Year: 2003}, false},
+	}
+	for _, tc := range testCases {
+		if got := kp.SameAs(tc.other); got != tc.same {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.same, got)
+		}
+	}
+}
+
+func TestComment_React(t *testing.T) {
+	var c Comment
+	c.React("a", "👍")
+	c.React("b", "👍")
+	c.React("a", "🔥")
+	if len(c.Reactions["👍"]) != 2 || len(c.Reactions["🔥"]) != 1 {
+		t.Fatalf("expected reactions of both users, got: %v", c.Reactions)
+	}
+
+	c.React("a", "👍")
+	c.React("a", "🔥")
+	if users := c.Reactions["👍"]; len(users) != 1 || users[0] != "b" {
+		t.Errorf("expected the reaction of a to be taken back, got: %v", c.Reactions)
+	}
+	if _, ok := c.Reactions["🔥"]; ok {
+		t.Errorf("expected the emoji without users to be removed, got: %v", c.Reactions)
+	}
+}
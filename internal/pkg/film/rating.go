@@ -2,7 +2,6 @@ package film
 
 import (
 	"math"
-	"sort"
 )
 
 const (
@@ -14,60 +13,15 @@ type (
 	Items []Item
 )
 
-func (f Items) SortKinopoisk() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].RatingKinopoisk
-		b := f[j].RatingKinopoisk
-		if a == b {
-			return f[i].Title < f[j].Title
+// WithStatus keeps the films that are in the user's watchlist with the status
+func (f Items) WithStatus(userID string, status Status) Items {
+	res := make(Items, 0, len(f))
+	for i := range f {
+		if f[i].Statuses[userID] == status {
+			res = append(res, f[i])
 		}
-		return a > b
-	})
-}
-
-func (f Items) SortUpdatedAt() {
-	sort.Slice(f, func(i, j int) bool {
-		return f[i].UpdatedAt.After(f[j].UpdatedAt)
-	})
-}
-
-func (f Items) SortCreatedAt() {
-	sort.Slice(f, func(i, j int) bool {
-		return f[i].CreatedAt.After(f[j].CreatedAt)
-	})
-}
-
-func (f Items) SortLexicographic() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].Title
-		b := f[j].Title
-		if a == b {
-			return f[i].ID < f[j].ID
-		}
-		return a < b
-	})
-}
-
-func (f Items) SortIMDB() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].RatingImdb
-		b := f[j].RatingImdb
-		if a == b {
-			return f[i].Title < f[j].Title
-		}
-		return a > b
-	})
-}
-
-func (f Items) SortScoreNumber() {
-	sort.Slice(f, func(i, j int) bool {
-		a := len(f[i].Scores)
-		b := len(f[j].Scores)
-		if a == b {
-			return f[i].Title < f[j].Title
-		}
-		return a > b
-	})
+	}
+	return res
 }
 
 func (f *Item) Average() Rate {
@@ -82,17 +36,6 @@ func (f *Item) Average() Rate {
 	return rate
 }
 
-func (f Items) SortAverage() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].Average()
-		b := f[j].Average()
-		if a == b {
-			return f[i].Title < f[j].Title
-		}
-		return a > b
-	})
-}
-
 func (f *Item) Sum() Rate {
 	var rate Rate
 	for _, v := range f.Scores {
@@ -101,33 +44,11 @@ func (f *Item) Sum() Rate {
 	return rate
 }
 
-func (f Items) SortSum() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].Sum()
-		b := f[j].Sum()
-		if a == b {
-			return f[i].Title < f[j].Title
-		}
-		return a > b
-	})
-}
-
 // Halva = abs(Average) * Sum
 func (f *Item) Halva() Rate {
 	return Rate(math.Abs(float64(f.Average()))) * f.Sum()
 }
 
-func (f Items) SortHalva() {
-	sort.Slice(f, func(i, j int) bool {
-		a := f[i].Halva()
-		b := f[j].Halva()
-		if a == b {
-			return f[i].Title < f[j].Title
-		}
-		return a > b
-	})
-}
-
 func (r Rate) Round() Rate {
 	return Rate(math.Round(float64(r)*publicPrecision) / publicPrecision)
 }
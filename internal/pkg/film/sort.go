@@ -0,0 +1,176 @@
+package film
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Comparator returns a negative number when a goes before b, a positive one when after and 0 if they are equal
+type Comparator func(a, b *Item) int
+
+const sortKeyRating = "rating"
+
+var (
+	ErrUnknownSortKey   = errors.New("unknown sort key")
+	ErrAmbiguousSortKey = errors.New("sort key needs a direction")
+)
+
+// Value is a sort key of a film, texts are compared first and numbers after them
+type Value struct {
+	Text   string  `json:"t,omitempty"`
+	Number float64 `json:"n,omitempty"`
+}
+
+func (v Value) compare(other Value) int {
+	if c := strings.Compare(v.Text, other.Text); c != 0 {
+		return c
+	}
+	return compareFloat(v.Number, other.Number)
+}
+
+var sortKeys = map[string]func(f *Item) Value{
+	"title":        func(f *Item) Value { return Value{Text: f.Title} },
+	"kinopoisk":    func(f *Item) Value { return Value{Number: f.RatingKinopoisk} },
+	"imdb":         func(f *Item) Value { return Value{Number: f.RatingImdb} },
+	"halva":        func(f *Item) Value { return Value{Number: float64(f.Halva())} },
+	"sum":          func(f *Item) Value { return Value{Number: float64(f.Sum())} },
+	"average":      func(f *Item) Value { return Value{Number: float64(f.Average())} },
+	"score_number": func(f *Item) Value { return Value{Number: float64(len(f.Scores))} },
+	"year":         func(f *Item) Value { return Value{Number: float64(f.Year)} },
+	"length":       func(f *Item) Value { return Value{Number: float64(f.FilmLength)} },
+	"created":      func(f *Item) Value { return Value{Number: float64(f.CreatedAt.UnixMicro())} },
+	"updated":      func(f *Item) Value { return Value{Number: float64(f.UpdatedAt.UnixMicro())} },
+}
+
+// legacySorts keep the meaning of the single sort modes that existed before sort expressions
+var legacySorts = map[string]string{
+	"lexicographic": "title",
+	"kinopoisk":     "-kinopoisk,title",
+	"imdb":          "-imdb,title",
+	"halva":         "-halva,title",
+	"sum":           "-sum,title",
+	"average":       "-average,title",
+	"score_number":  "-score_number,title",
+	"created":       "-created",
+	"updated":       "-updated",
+}
+
+// Order is a parsed sort expression, it can both compare films and remember the position of a film in the order
+type Order struct {
+	keys []orderKey
+}
+
+type orderKey struct {
+	value func(f *Item) Value
+	desc  bool
+}
+
+// ParseSort parses a comma separated list of keys like "-halva,year,title", see ParseOrder
+func ParseSort(expr string, rating RatingStrategy) (Comparator, error) {
+	order, err := ParseOrder(expr, rating)
+	if err != nil {
+		return nil, err
+	}
+	return order.Compare, nil
+}
+
+// ParseOrder parses a comma separated list of keys like "-halva,year,title", "-" is descending and "+" is ascending.
+// A single key keeps its legacy mode, e.g. "halva" is "-halva,title", so in a list such keys need an explicit direction.
+// The "rating" key compares films by the rating strategy.
+func ParseOrder(expr string, rating RatingStrategy) (*Order, error) {
+	if legacy, ok := legacySorts[expr]; ok {
+		expr = legacy
+	}
+
+	keys := strings.Split(expr, ",")
+	order := &Order{keys: make([]orderKey, 0, len(keys))}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		name := strings.TrimLeft(key, "-+")
+		if _, legacy := legacySorts[name]; legacy && name == key {
+			return nil, errors.Wrapf(ErrAmbiguousSortKey, "use \"-%s\" or \"+%s\" (%%2B%s in a url)", name, name, name)
+		}
+		value, ok := sortKeys[name]
+		if name == sortKeyRating && rating != nil {
+			value, ok = func(f *Item) Value { return Value{Number: float64(rating.Rate(f))} }, true
+		}
+		if !ok {
+			return nil, errors.Wrapf(ErrUnknownSortKey, "%q, use one of (%s)", key, strings.Join(SortKeys(), ", "))
+		}
+		order.keys = append(order.keys, orderKey{value: value, desc: strings.HasPrefix(key, "-")})
+	}
+	return order, nil
+}
+
+// Compare is the Comparator of the order
+func (o *Order) Compare(a, b *Item) int {
+	for _, k := range o.keys {
+		if c := k.compare(k.value(a), k.value(b)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Key returns the values the film is ordered by
+func (o *Order) Key(f *Item) []Value {
+	key := make([]Value, 0, len(o.keys))
+	for _, k := range o.keys {
+		key = append(key, k.value(f))
+	}
+	return key
+}
+
+// After reports whether the film goes after the film with the key and the ID when sorted by SortBy
+func (o *Order) After(f *Item, key []Value, id string) bool {
+	if len(key) != len(o.keys) {
+		return false
+	}
+	for i, k := range o.keys {
+		if c := k.compare(k.value(f), key[i]); c != 0 {
+			return c > 0
+		}
+	}
+	return f.ID > id
+}
+
+func (k orderKey) compare(a, b Value) int {
+	if k.desc {
+		return b.compare(a)
+	}
+	return a.compare(b)
+}
+
+// SortKeys returns the keys known to ParseSort
+func SortKeys() []string {
+	keys := make([]string, 0, len(sortKeys)+1)
+	for k := range sortKeys {
+		keys = append(keys, k)
+	}
+	keys = append(keys, sortKeyRating)
+	sort.Strings(keys)
+	return keys
+}
+
+// SortBy orders the films by the comparator, films equal by it are ordered by ID so the order is always the same
+func (f Items) SortBy(cmp Comparator) {
+	sort.Slice(f, func(i, j int) bool {
+		if c := cmp(&f[i], &f[j]); c != 0 {
+			return c < 0
+		}
+		return f[i].ID < f[j].ID
+	})
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,49 @@
+package film
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseSort(t *testing.T) {
+	items := Items{
+		{ID: "1", Title: "B", Year: 2000, Scores: map[string]Score{"a": GoodScore}},
+		{ID: "2", Title: "A", Year: 2010, Scores: map[string]Score{"a": GoodScore}},
+		{ID: "3", Title: "C", Year: 2000, Scores: map[string]Score{"a": ExcellentScore}},
+		{ID: "4", Title: "A", Year: 2000, Scores: map[string]Score{"a": GoodScore}},
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "-halva,year,title", want: "3412"},
+		{expr: "-year,-title", want: "2314"},
+		{expr: "title", want: "2413"},
+		{expr: "halva", want: "3241"},
+		{expr: "+halva,title", want: "2413"},
+		{expr: "-rating,-title", want: "3124"},
+	}
+	for _, tt := range tests {
+		cmp, err := ParseSort(tt.expr, halvaRating{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		items.SortBy(cmp)
+		var got string
+		for i := range items {
+			got += items[i].ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected: %s, got: %s", tt.expr, tt.want, got)
+		}
+	}
+
+	if _, err := ParseSort("-halva,rank", halvaRating{}); !errors.Is(err, ErrUnknownSortKey) {
+		t.Errorf("expected ErrUnknownSortKey, got: %v", err)
+	}
+	if _, err := ParseSort("halva,title", halvaRating{}); !errors.Is(err, ErrAmbiguousSortKey) {
+		t.Errorf("expected ErrAmbiguousSortKey for a legacy mode in a list, got: %v", err)
+	}
+}
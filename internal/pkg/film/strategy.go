@@ -0,0 +1,148 @@
+package film
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RatingHalva    = "halva"
+	RatingBayesian = "bayesian"
+	RatingWilson   = "wilson"
+
+	defaultPriorWeight = 2
+	defaultWilsonZ     = 1.96
+)
+
+var ErrUnknownRating = errors.New("unknown rating strategy")
+
+// RatingStrategy turns the scores of the group into a single rating of the film
+type RatingStrategy interface {
+	Name() string
+	Rate(f *Item) Rate
+}
+
+// RatingConfig selects the default strategy and tunes the formulas.
+// Prior is the score a film is expected to get before anyone scored it, PriorWeight is how many scores the prior is worth.
+// WilsonZ is the z-score of the Wilson interval, 1.96 is 95% confidence.
+type RatingConfig struct {
+	Strategy    string  `yaml:"strategy"`
+	Prior       float64 `yaml:"prior"`
+	PriorWeight float64 `yaml:"prior_weight" split_words:"true"`
+	WilsonZ     float64 `yaml:"wilson_z" split_words:"true"`
+}
+
+type ratings struct {
+	all         []RatingStrategy
+	byName      map[string]RatingStrategy
+	defaultName string
+}
+
+func NewRatings(cfg RatingConfig) (*ratings, error) {
+	if cfg.PriorWeight < 0 {
+		return nil, errors.Errorf("prior weight %v should not be negative", cfg.PriorWeight)
+	}
+	if cfg.Prior < float64(BadScore) || cfg.Prior > float64(ExcellentScore) {
+		return nil, errors.Errorf("prior %v should be a score from %d to %d", cfg.Prior, BadScore, ExcellentScore)
+	}
+	if cfg.PriorWeight == 0 {
+		cfg.PriorWeight = defaultPriorWeight
+	}
+	if cfg.WilsonZ == 0 {
+		cfg.WilsonZ = defaultWilsonZ
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = RatingHalva
+	}
+
+	r := &ratings{
+		all: []RatingStrategy{
+			halvaRating{},
+			bayesianRating{prior: Rate(cfg.Prior), weight: Rate(cfg.PriorWeight)},
+			wilsonRating{z: cfg.WilsonZ},
+		},
+		byName:      make(map[string]RatingStrategy, 3),
+		defaultName: cfg.Strategy,
+	}
+	for i := range r.all {
+		r.byName[r.all[i].Name()] = r.all[i]
+	}
+	if _, ok := r.byName[cfg.Strategy]; !ok {
+		return nil, errors.Wrapf(ErrUnknownRating, "%q", cfg.Strategy)
+	}
+	return r, nil
+}
+
+// Get returns the strategy by name or the default one if the name is empty
+func (r *ratings) Get(name string) (RatingStrategy, error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	s, ok := r.byName[name]
+	if !ok {
+		names := make([]string, 0, len(r.byName))
+		for k := range r.byName {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, errors.Wrapf(ErrUnknownRating, "%q, use one of %v", name, names)
+	}
+	return s, nil
+}
+
+func (r *ratings) All() []RatingStrategy {
+	return r.all
+}
+
+type halvaRating struct{}
+
+func (halvaRating) Name() string {
+	return RatingHalva
+}
+
+func (halvaRating) Rate(f *Item) Rate {
+	return f.Halva()
+}
+
+// bayesianRating is the average of the scores mixed with PriorWeight imaginary scores equal to Prior,
+// so a single excellent score does not beat many good ones
+type bayesianRating struct {
+	prior  Rate
+	weight Rate
+}
+
+func (bayesianRating) Name() string {
+	return RatingBayesian
+}
+
+func (b bayesianRating) Rate(f *Item) Rate {
+	return (b.weight*b.prior + f.Sum()) / (b.weight + Rate(len(f.Scores)))
+}
+
+// wilsonRating is the lower bound of the Wilson score interval for the share of good and excellent scores
+type wilsonRating struct {
+	z float64
+}
+
+func (wilsonRating) Name() string {
+	return RatingWilson
+}
+
+func (w wilsonRating) Rate(f *Item) Rate {
+	n := float64(len(f.Scores))
+	if n == 0 {
+		return 0
+	}
+	var positive float64
+	for _, v := range f.Scores {
+		if v >= GoodScore {
+			positive++
+		}
+	}
+
+	p, z2 := positive/n, w.z*w.z
+	bound := (p + z2/(2*n) - w.z*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+	return Rate(bound)
+}
@@ -0,0 +1,48 @@
+package film
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRatings(t *testing.T) {
+	r, err := NewRatings(RatingConfig{Strategy: RatingBayesian, PriorWeight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single := &Item{Scores: map[string]Score{"a": ExcellentScore}}
+	many := &Item{Scores: map[string]Score{"a": GoodScore, "b": GoodScore, "c": GoodScore, "d": ExcellentScore, "e": GoodScore}}
+
+	bayesian, _ := r.Get("")
+	if bayesian.Name() != RatingBayesian {
+		t.Fatalf("expected the default strategy to be bayesian, got: %s", bayesian.Name())
+	}
+	if a, b := bayesian.Rate(single), bayesian.Rate(many); a >= b {
+		t.Errorf("a single excellent score (%.3f) should not beat many good ones (%.3f)", a, b)
+	}
+
+	wilson, _ := r.Get(RatingWilson)
+	// 5 positive of 5 with z = 1.96
+	if got := float64(wilson.Rate(many)); math.Abs(got-0.5655) > 1e-3 {
+		t.Errorf("expected wilson bound 0.5655, got: %.4f", got)
+	}
+	if got := wilson.Rate(&Item{}); got != 0 {
+		t.Errorf("expected 0 without scores, got: %v", got)
+	}
+
+	if _, err := r.Get("imdb"); !errors.Is(err, ErrUnknownRating) {
+		t.Errorf("expected ErrUnknownRating, got: %v", err)
+	}
+	if _, err := NewRatings(RatingConfig{Strategy: "imdb"}); !errors.Is(err, ErrUnknownRating) {
+		t.Errorf("expected ErrUnknownRating for the config, got: %v", err)
+	}
+	if _, err := NewRatings(RatingConfig{PriorWeight: -1}); err == nil {
+		t.Error("expected an error for a negative prior weight")
+	}
+	if _, err := NewRatings(RatingConfig{Prior: 3}); err == nil {
+		t.Error("expected an error for a prior outside of the scores")
+	}
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"fmt"
+	"time"
 
 	"cloud.google.com/go/firestore"
 
@@ -9,14 +10,29 @@ import (
 	"github.com/HalvaPovidlo/halva-services/internal/pkg/song"
 )
 
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+var rolePriority = map[string]int{
+	RoleGuest:  1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+}
+
 type Items []Item
 
 type Item struct {
-	ID       string                `firestore:"-" json:"id"`
-	Username string                `firestore:"username" json:"username,omitempty"`
-	Avatar   string                `firestore:"avatar,omitempty" json:"avatar,omitempty"`
-	Scores   map[string]film.Score `firestore:"scores" json:"scores,omitempty"`
-	Songs    map[string]song.Item  `firestore:"-" json:"songs,omitempty"`
+	ID       string                 `firestore:"-" json:"id"`
+	Username string                 `firestore:"username" json:"username,omitempty"`
+	Avatar   string                 `firestore:"avatar,omitempty" json:"avatar,omitempty"`
+	Role     string                 `firestore:"role,omitempty" json:"role,omitempty"`
+	Scores   map[string]film.Score  `firestore:"scores" json:"scores,omitempty"`
+	Statuses map[string]film.Status `firestore:"statuses,omitempty" json:"statuses,omitempty"`
+	LastRead map[string]time.Time   `firestore:"last_read,omitempty" json:"last_read,omitempty"` // film -> when its comments were read
+	Songs    map[string]song.Item   `firestore:"-" json:"songs,omitempty"`
 }
 
 func Parse(doc *firestore.DocumentSnapshot) (*Item, error) {
@@ -27,3 +43,17 @@ func Parse(doc *firestore.DocumentSnapshot) (*Item, error) {
 	u.ID = doc.Ref.ID
 	return &u, nil
 }
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleMember, RoleGuest:
+		return true
+	default:
+		return false
+	}
+}
+
+// RoleAbove reports whether the role is more privileged than the other one, an empty role is below any role
+func RoleAbove(role, other string) bool {
+	return rolePriority[role] > rolePriority[other]
+}
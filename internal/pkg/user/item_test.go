@@ -0,0 +1,12 @@
+package user
+
+import "testing"
+
+func TestRoleAbove(t *testing.T) {
+	if !RoleAbove(RoleAdmin, RoleMember) || !RoleAbove(RoleMember, RoleGuest) || !RoleAbove(RoleGuest, "") {
+		t.Error("expected a more privileged role to be above")
+	}
+	if RoleAbove(RoleMember, RoleAdmin) || RoleAbove("", RoleGuest) || RoleAbove(RoleMember, RoleMember) {
+		t.Error("expected a less or equally privileged role not to be above")
+	}
+}
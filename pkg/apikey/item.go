@@ -0,0 +1,61 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+)
+
+const (
+	ScopeFilmsRead    = "films:read"
+	ScopeFilmsWrite   = "films:write"
+	ScopeMusicControl = "music:control"
+)
+
+var scopes = []string{ScopeFilmsRead, ScopeFilmsWrite, ScopeMusicControl}
+
+// Key is stored by the hash of its secret, the secret itself is shown only once to the owner
+type Key struct {
+	ID        string    `firestore:"-"`
+	Name      string    `firestore:"name"`
+	OwnerID   string    `firestore:"owner_id"`
+	Role      string    `firestore:"role"`
+	Scopes    []string  `firestore:"scopes"`
+	CreatedAt time.Time `firestore:"created_at"`
+	LastUsed  time.Time `firestore:"last_used,omitempty"`
+}
+
+func (k *Key) HasScope(scope string) bool {
+	for i := range k.Scopes {
+		if k.Scopes[i] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidScope(scope string) bool {
+	for i := range scopes {
+		if scopes[i] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func Parse(doc *firestore.DocumentSnapshot) (*Key, error) {
+	var k Key
+	if err := doc.DataTo(&k); err != nil {
+		return nil, errors.Wrap(err, "unmarshall data")
+	}
+	k.ID = doc.Ref.ID
+	return &k, nil
+}
+
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,154 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"time"
+
+	pcache "github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/HalvaPovidlo/halva-services/internal/pkg/user"
+	"github.com/HalvaPovidlo/halva-services/pkg/contexts"
+)
+
+const (
+	keyPrefix = "halva_"
+	keySize   = 32
+	// verifyTTL bounds how long a revoked key is still accepted by other services
+	verifyTTL     = time.Minute
+	touchInterval = 5 * time.Minute
+)
+
+var ErrNotFound = errors.New("api key not found")
+
+type storageService interface {
+	Get(ctx context.Context, id string) (*Key, error)
+	Set(ctx context.Context, key *Key) error
+	All(ctx context.Context, ownerID string) ([]Key, error)
+	Delete(ctx context.Context, id string) error
+	Touch(ctx context.Context, id string, lastUsed time.Time) error
+	OwnerRole(ctx context.Context, ownerID string) (string, error)
+}
+
+type service struct {
+	storage  storageService
+	verified *pcache.Cache // key hash -> Key
+}
+
+func New(storage storageService) *service {
+	return &service{
+		storage:  storage,
+		verified: pcache.New(verifyTTL, verifyTTL),
+	}
+}
+
+// Issue creates a key acting as the owner with the given role, the returned secret is not stored anywhere
+func (s *service) Issue(ctx context.Context, ownerID, role, name string, scopes []string) (string, *Key, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, errors.Wrap(err, "generate api key")
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key := &Key{
+		ID:        hashKey(secret),
+		Name:      name,
+		OwnerID:   ownerID,
+		Role:      role,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.Set(ctx, key); err != nil {
+		return "", nil, errors.Wrap(err, "save api key")
+	}
+	return secret, key, nil
+}
+
+// Keys returns the keys of the owner or every key if the owner is empty, newest first
+func (s *service) Keys(ctx context.Context, ownerID string) ([]Key, error) {
+	keys, err := s.storage.All(ctx, ownerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get api keys from storage")
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeAll deletes every key of the owner, it is done when the role of the owner is lowered
+func (s *service) RevokeAll(ctx context.Context, ownerID string) error {
+	keys, err := s.storage.All(ctx, ownerID)
+	if err != nil {
+		return errors.Wrap(err, "get api keys from storage")
+	}
+	for i := range keys {
+		if err := s.storage.Delete(ctx, keys[i].ID); err != nil {
+			return errors.Wrap(err, "delete api key")
+		}
+		s.verified.Delete(keys[i].ID)
+	}
+	return nil
+}
+
+// Revoke deletes the key, with non-empty ownerID only the owner's keys can be revoked
+func (s *service) Revoke(ctx context.Context, id, ownerID string) error {
+	key, err := s.storage.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ownerID != "" && key.OwnerID != ownerID {
+		return ErrNotFound
+	}
+	if err := s.storage.Delete(ctx, id); err != nil {
+		return errors.Wrap(err, "delete api key")
+	}
+	s.verified.Delete(id)
+	return nil
+}
+
+// VerifyAPIKey resolves the owner, role and scopes of the key and records its usage,
+// the role is the current role of the owner capped by the role of the key
+func (s *service) VerifyAPIKey(ctx context.Context, secret string) (string, string, []string, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return "", "", nil, ErrNotFound
+	}
+
+	id := hashKey(secret)
+	var key Key
+	ttl := verifyTTL
+	if v, expiration, ok := s.verified.GetWithExpiration(id); ok {
+		// the cached copy must not outlive verifyTTL, otherwise a busy key is never revoked
+		key, ttl = v.(Key), time.Until(expiration)
+	} else {
+		stored, err := s.storage.Get(ctx, id)
+		if err != nil {
+			return "", "", nil, err
+		}
+		ownerRole, err := s.storage.OwnerRole(ctx, stored.OwnerID)
+		if err != nil {
+			return "", "", nil, errors.Wrap(err, "get api key owner role")
+		}
+		// a key never acts above the current role of its owner
+		if user.RoleAbove(stored.Role, ownerRole) {
+			stored.Role = ownerRole
+		}
+		key = *stored
+	}
+
+	if now := time.Now(); now.Sub(key.LastUsed) > touchInterval {
+		key.LastUsed = now
+		if err := s.storage.Touch(ctx, id, now); err != nil {
+			contexts.GetLogger(ctx).Warn("failed to update api key last used", zap.String("name", key.Name), zap.Error(err))
+		}
+	}
+	if ttl > 0 {
+		s.verified.Set(id, key, ttl)
+	}
+	return key.OwnerID, key.Role, key.Scopes, nil
+}
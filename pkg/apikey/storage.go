@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fire "github.com/HalvaPovidlo/halva-services/pkg/firestore"
+)
+
+const approximateKeysNumber = 10
+
+type storage struct {
+	*firestore.Client
+}
+
+func NewStorage(client *firestore.Client) *storage {
+	return &storage{
+		Client: client,
+	}
+}
+
+func (s *storage) Get(ctx context.Context, id string) (*Key, error) {
+	doc, err := s.Collection(fire.APIKeysCollection).Doc(id).Get(ctx)
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, errors.Wrap(err, "get api key doc")
+	}
+	return Parse(doc)
+}
+
+func (s *storage) Set(ctx context.Context, key *Key) error {
+	_, err := s.Collection(fire.APIKeysCollection).Doc(key.ID).Set(ctx, key)
+	return errors.Wrap(err, "set api key doc")
+}
+
+// All returns the keys of the owner or every key if the owner is empty
+func (s *storage) All(ctx context.Context, ownerID string) ([]Key, error) {
+	query := s.Collection(fire.APIKeysCollection).Query
+	if ownerID != "" {
+		query = query.Where("owner_id", "==", ownerID)
+	}
+
+	keys := make([]Key, 0, approximateKeysNumber)
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "get next iterator")
+		}
+		k, err := Parse(doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse api key doc")
+		}
+		keys = append(keys, *k)
+	}
+	return keys, nil
+}
+
+func (s *storage) Delete(ctx context.Context, id string) error {
+	_, err := s.Collection(fire.APIKeysCollection).Doc(id).Delete(ctx)
+	return errors.Wrap(err, "delete api key doc")
+}
+
+func (s *storage) Touch(ctx context.Context, id string, lastUsed time.Time) error {
+	_, err := s.Collection(fire.APIKeysCollection).Doc(id).Update(ctx, []firestore.Update{{Path: "last_used", Value: lastUsed}})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "update api key last used")
+}
+
+// OwnerRole returns the current role of the user, it is empty if the user is gone
+func (s *storage) OwnerRole(ctx context.Context, ownerID string) (string, error) {
+	doc, err := s.Collection(fire.UsersCollection).Doc(ownerID).Get(ctx)
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return "", nil
+	case err != nil:
+		return "", errors.Wrap(err, "get user doc")
+	}
+	// users without a role have no such field
+	field, _ := doc.DataAt("role")
+	role, _ := field.(string)
+	return role, nil
+}
@@ -22,9 +22,15 @@ type service struct {
 	echo *echo.Echo
 }
 
-func New() *service {
+// New creates the echo server, requests with credentials are allowed only from credentialOrigins
+func New(credentialOrigins ...string) *service {
 	e := echo.New()
-	e.Use(middleware.CORSWithConfig(middleware.DefaultCORSConfig))
+	cors := middleware.DefaultCORSConfig
+	if len(credentialOrigins) != 0 {
+		cors.AllowOrigins = credentialOrigins
+		cors.AllowCredentials = true
+	}
+	e.Use(middleware.CORSWithConfig(cors))
 	return &service{
 		echo: e,
 	}
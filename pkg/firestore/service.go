@@ -10,12 +10,19 @@ import (
 )
 
 const (
-	SongsCollection    = "songs"
-	UsersCollection    = "users"
-	FilmsCollection    = "films"
-	CommentsCollection = "comments"
-	LoginsCollection   = "logins"
-	BatchSize          = 500
+	SongsCollection      = "songs"
+	UsersCollection      = "users"
+	FilmsCollection      = "films"
+	CommentsCollection   = "comments"
+	LoginsCollection     = "logins"
+	RefreshCollection    = "refresh_tokens"
+	SessionsCollection   = "sessions"
+	AllowedCollection    = "allowed_users"
+	InvitesCollection    = "invites"
+	APIKeysCollection    = "api_keys"
+	WatchesCollection    = "watch_sessions"
+	MigrationsCollection = "migrations"
+	BatchSize            = 500
 )
 
 func New(ctx context.Context, creds string) (*firestore.Client, error) {
@@ -0,0 +1,158 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyTypeRSA = "RSA"
+	keyTypeOKP = "OKP"
+	curveEd    = "Ed25519"
+	keyUseSig  = "sig"
+)
+
+type KeyConfig struct {
+	ID   string `yaml:"id"`
+	Path string `yaml:"path"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type privateKey struct {
+	id     string
+	key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// loadPrivateKey reads PKCS#1 or PKCS#8 PEM encoded RSA or Ed25519 private key
+func loadPrivateKey(cfg KeyConfig) (*privateKey, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("key id is empty")
+	}
+	data, err := os.ReadFile(filepath.Clean(cfg.Path))
+	if err != nil {
+		return nil, errors.Wrap(err, "read key file")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key file is not PEM encoded")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &privateKey{id: cfg.ID, key: k, method: jwt.SigningMethodRS256}, nil
+	case ed25519.PrivateKey:
+		return &privateKey{id: cfg.ID, key: k, method: jwt.SigningMethodEdDSA}, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", key)
+	}
+}
+
+func toJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: keyTypeRSA,
+			Kid: kid,
+			Use: keyUseSig,
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: keyTypeOKP,
+			Kid: kid,
+			Use: keyUseSig,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Crv: curveEd,
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return JWK{}, errors.Errorf("unsupported public key type %T", key)
+	}
+}
+
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case keyTypeRSA:
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case keyTypeOKP:
+		if k.Crv != curveEd {
+			return nil, errors.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode public key")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// methodMatches protects from algorithm confusion: the token must be signed with the algorithm of the key
+func methodMatches(method jwt.SigningMethod, key interface{}) bool {
+	switch key.(type) {
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	case *rsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodRSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	default:
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultJWKSRefresh = time.Hour
+	minJWKSRefetch     = 10 * time.Second
+	jwksFetchTimeout   = 5 * time.Second
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type staticKeys map[string]interface{}
+
+func (s staticKeys) Key(kid string) (interface{}, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// remoteKeys caches public keys from a JWKS url or file, the set is reloaded
+// every refresh interval or when a token is signed with a key that is not known yet,
+// but not more often than minJWKSRefetch, so tokens with random kids cannot flood the source
+type remoteKeys struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mx      *sync.RWMutex
+	keys    map[string]interface{}
+	fetched time.Time
+
+	fetchMx   *sync.Mutex
+	attempted time.Time // the last fetch, failed ones too
+}
+
+func newRemoteKeys(source string, refresh time.Duration) *remoteKeys {
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	return &remoteKeys{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+		mx:      &sync.RWMutex{},
+		keys:    make(map[string]interface{}),
+		fetchMx: &sync.Mutex{},
+	}
+}
+
+func (r *remoteKeys) Key(kid string) (interface{}, error) {
+	r.mx.RLock()
+	key, ok := r.keys[kid]
+	since := time.Since(r.fetched)
+	r.mx.RUnlock()
+
+	if ok && since < r.refresh {
+		return key, nil
+	}
+
+	if err := r.refetch(); err != nil {
+		if ok {
+			return key, nil
+		}
+		return nil, errors.Wrap(err, "fetch jwks")
+	}
+
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	if key, ok := r.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refetch fetches the keys unless it was tried less than minJWKSRefetch ago, concurrent calls wait for a single fetch
+func (r *remoteKeys) refetch() error {
+	r.fetchMx.Lock()
+	defer r.fetchMx.Unlock()
+
+	if time.Since(r.attempted) < minJWKSRefetch {
+		return nil
+	}
+	r.attempted = time.Now()
+	return r.fetch()
+}
+
+func (r *remoteKeys) fetch() error {
+	data, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	var set JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return errors.Wrap(err, "unmarshall jwks")
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for i := range set.Keys {
+		key, err := set.Keys[i].PublicKey()
+		if err != nil {
+			return errors.Wrapf(err, "parse key %s", set.Keys[i].Kid)
+		}
+		keys[set.Keys[i].Kid] = key
+	}
+
+	r.mx.Lock()
+	r.keys = keys
+	r.fetched = time.Now()
+	r.mx.Unlock()
+	return nil
+}
+
+func (r *remoteKeys) read() ([]byte, error) {
+	if !strings.HasPrefix(r.source, "http://") && !strings.HasPrefix(r.source, "https://") {
+		data, err := os.ReadFile(filepath.Clean(r.source))
+		return data, errors.Wrap(err, "read jwks file")
+	}
+
+	resp, err := r.client.Get(r.source)
+	if err != nil {
+		return nil, errors.Wrap(err, "do http request")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("status not ok: " + resp.Status)
+	}
+	return data, nil
+}
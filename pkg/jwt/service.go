@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"context"
+	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -12,41 +14,132 @@ import (
 const (
 	contextKey  = "jwt_key"
 	userIDClaim = "userID_jwt"
+	roleClaim   = "role_jwt"
+	scopesClaim = "scopes_jwt"
+	kidHeader   = "kid"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+var (
+	TokenTTL = time.Second * 10
+
+	ErrNoSigningKey     = errors.New("signing key is not configured")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
 )
 
-var TokenTTL = time.Second * 10
+// Config describes how tokens are signed and verified.
+// Keys are private keys of the issuer, the first one signs new tokens and the rest are still accepted during rotation.
+// JWKS is an url or a file with public keys of the issuer for the services that only verify tokens.
+// Secret is a shared HS256 secret, used only when neither Keys nor JWKS are set.
+type Config struct {
+	Keys        []KeyConfig   `yaml:"keys"`
+	JWKS        string        `yaml:"jwks"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh" split_words:"true"`
+	Secret      string        `yaml:"secret"`
+}
 
 type Claims struct {
 	UserID string `json:"userID"`
+	Role   string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
+type keySet interface {
+	Key(kid string) (interface{}, error)
+}
+
+// apiKeys resolves long-lived keys of bots and scripts into the owner, role and scopes of the key
+type apiKeys interface {
+	VerifyAPIKey(ctx context.Context, key string) (string, string, []string, error)
+}
+
 type service struct {
-	secret        []byte
+	signingKey    interface{}
 	signingMethod jwt.SigningMethod
+	kid           string
+	keys          keySet
+	jwks          JWKSet
+	apiKeys       apiKeys
 }
 
-func New(secret string) *service {
-	return &service{
-		secret:        []byte(secret),
-		signingMethod: jwt.SigningMethodHS256,
+func New(cfg Config) (*service, error) {
+	switch {
+	case len(cfg.Keys) > 0:
+		return newSigner(cfg.Keys)
+	case cfg.JWKS != "":
+		return &service{keys: newRemoteKeys(cfg.JWKS, cfg.JWKSRefresh)}, nil
+	case cfg.Secret != "":
+		return &service{
+			signingKey:    []byte(cfg.Secret),
+			signingMethod: jwt.SigningMethodHS256,
+			keys:          staticKeys{"": []byte(cfg.Secret)},
+		}, nil
+	default:
+		return nil, errors.New("neither keys, jwks nor secret are configured")
 	}
 }
 
-func (s *service) Generate(userID string) (string, error) {
+func newSigner(cfgs []KeyConfig) (*service, error) {
+	keys := make(staticKeys, len(cfgs))
+	s := &service{
+		keys: keys,
+		jwks: JWKSet{Keys: make([]JWK, 0, len(cfgs))},
+	}
+	for i := range cfgs {
+		key, err := loadPrivateKey(cfgs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "load key %s", cfgs[i].ID)
+		}
+		if _, ok := keys[key.id]; ok {
+			return nil, errors.Errorf("duplicate key id %s", key.id)
+		}
+		if i == 0 {
+			s.signingKey, s.signingMethod, s.kid = key.key, key.method, key.id
+		}
+
+		public := key.key.Public()
+		jwk, err := toJWK(key.id, public)
+		if err != nil {
+			return nil, errors.Wrapf(err, "build jwk %s", key.id)
+		}
+		keys[key.id] = public
+		s.jwks.Keys = append(s.jwks.Keys, jwk)
+	}
+	return s, nil
+}
+
+func (s *service) Generate(userID, role string) (string, error) {
+	if s.signingKey == nil {
+		return "", ErrNoSigningKey
+	}
 	token := jwt.NewWithClaims(s.signingMethod, &Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL)),
 		},
 	})
+	if s.kid != "" {
+		token.Header[kidHeader] = s.kid
+	}
+
+	hash, err := token.SignedString(s.signingKey)
+	return hash, errors.Wrap(err, "sign jwt")
+}
+
+// JWKS returns public keys of the signer, it is empty for verifiers and HS256
+func (s *service) JWKS() JWKSet {
+	return s.jwks
+}
 
-	hash, err := token.SignedString(s.secret)
-	return hash, errors.Wrap(err, "sign jwt with secret")
+// UseAPIKeys makes Authorization accept api keys from the X-API-Key header alongside bearer tokens
+func (s *service) UseAPIKeys(keys apiKeys) {
+	s.apiKeys = keys
 }
 
 func (s *service) Authorization(next echo.HandlerFunc) echo.HandlerFunc {
-	return s.tokenExtractor()(func(c echo.Context) error {
+	bearer := s.tokenExtractor()(func(c echo.Context) error {
 		token, ok := c.Get(contextKey).(*jwt.Token)
 		if !ok {
 			return errors.New("JWT token missing or invalid")
@@ -57,8 +150,59 @@ func (s *service) Authorization(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		c.Set(userIDClaim, claims.UserID)
+		c.Set(roleClaim, claims.Role)
 		return next(c)
 	})
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(APIKeyHeader)
+		if key == "" || s.apiKeys == nil {
+			return bearer(c)
+		}
+
+		userID, role, scopes, err := s.apiKeys.VerifyAPIKey(c.Request().Context(), key)
+		if err != nil {
+			return c.String(http.StatusUnauthorized, "Invalid API key")
+		}
+		c.Set(userIDClaim, userID)
+		c.Set(roleClaim, role)
+		c.Set(scopesClaim, scopes)
+		return next(c)
+	}
+}
+
+// RequireRole allows the request only for the listed roles, it must follow Authorization
+func (s *service) RequireRole(roles ...string) echo.MiddlewareFunc {
+	allowed := make(map[string]struct{}, len(roles))
+	for i := range roles {
+		allowed[roles[i]] = struct{}{}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := s.ExtractRole(c)
+			if _, ok := allowed[role]; !ok {
+				return c.String(http.StatusForbidden, "Not enough permissions")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireScope restricts api keys to the routes of their scopes, bearer tokens are not scoped
+func (s *service) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scopes, ok := c.Get(scopesClaim).([]string)
+			if !ok {
+				return next(c)
+			}
+			for i := range scopes {
+				if scopes[i] == scope {
+					return next(c)
+				}
+			}
+			return c.String(http.StatusForbidden, "API key is not allowed to access this route")
+		}
+	}
 }
 
 func (s *service) ExtractUserID(c echo.Context) (string, error) {
@@ -68,18 +212,36 @@ func (s *service) ExtractUserID(c echo.Context) (string, error) {
 	return "", errors.New("bad userID claim")
 }
 
+func (s *service) ExtractRole(c echo.Context) (string, error) {
+	if v, ok := c.Get(roleClaim).(string); ok {
+		return v, nil
+	}
+	return "", errors.New("bad role claim")
+}
+
 func (s *service) tokenExtractor() echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
-		ContextKey:    contextKey,
-		SigningKey:    s.secret,
-		SigningMethod: s.GetSigningMethod().Alg(),
-		TokenLookup:   "header:Authorization:Bearer ,query:token:",
+		ContextKey:  contextKey,
+		KeyFunc:     s.keyFunc,
+		TokenLookup: "header:Authorization:Bearer ,query:token:",
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return &Claims{}
 		},
 	})
 }
 
+func (s *service) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header[kidHeader].(string)
+	key, err := s.keys.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if !methodMatches(token.Method, key) {
+		return nil, ErrUnexpectedMethod
+	}
+	return key, nil
+}
+
 func (s *service) GetSigningMethod() jwt.SigningMethod {
 	return s.signingMethod
 }
@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+func writeKey(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path
+}
+
+func authorize(s *service, token string) (string, int) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	var userID string
+	err := s.Authorization(func(c echo.Context) error {
+		userID, _ = s.ExtractUserID(c)
+		return nil
+	})(c)
+	if he, ok := err.(*echo.HTTPError); ok {
+		return "", he.Code
+	}
+	return userID, http.StatusOK
+}
+
+func TestService_Rotation(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+
+	oldSigner, err := New(Config{Keys: []KeyConfig{{ID: "old", Path: writeKey(t, rsaKey)}}})
+	if err != nil {
+		t.Fatalf("new old signer: %v", err)
+	}
+	signer, err := New(Config{Keys: []KeyConfig{
+		{ID: "new", Path: writeKey(t, edKey)},
+		{ID: "old", Path: writeKey(t, rsaKey)},
+	}})
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+
+	data, _ := json.Marshal(signer.JWKS())
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwks, data, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	verifier, err := New(Config{JWKS: jwks})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	if _, err := verifier.Generate("user", ""); err != ErrNoSigningKey {
+		t.Errorf("expected verifier not to sign, got: %v", err)
+	}
+
+	for _, s := range []*service{signer, oldSigner} {
+		token, err := s.Generate("user", "")
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if id, code := authorize(verifier, token); code != http.StatusOK || id != "user" {
+			t.Errorf("expected token of %s to be accepted, got: %d", s.kid, code)
+		}
+	}
+
+	hmac, _ := New(Config{Secret: "secret"})
+	token, _ := hmac.Generate("user", "")
+	if _, code := authorize(verifier, token); code == http.StatusOK {
+		t.Errorf("expected HS256 token to be rejected")
+	}
+}
+
+func TestRemoteKeys_UnknownKid(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"keys":[]}`))
+		}))
+
+		keys := newRemoteKeys(server.URL, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := keys.Key(strconv.Itoa(i)); err == nil {
+					t.Errorf("expected kid %d to be unknown", i)
+				}
+			}(i)
+		}
+		wg.Wait()
+		server.Close()
+
+		if n := atomic.LoadInt32(&requests); n != 1 {
+			t.Errorf("status %d: expected unknown kids to fetch jwks once, got: %d", status, n)
+		}
+	}
+}
+
+func TestService_RequireRole(t *testing.T) {
+	s, _ := New(Config{Secret: "secret"})
+	handler := s.Authorization(s.RequireRole("admin")(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}))
+
+	for role, code := range map[string]int{"admin": http.StatusOK, "member": http.StatusForbidden, "": http.StatusForbidden} {
+		token, _ := s.Generate("user", role)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		if err := handler(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatalf("handler: %v", err)
+		}
+		if rec.Code != code {
+			t.Errorf("role %q: expected %d, got: %d", role, code, rec.Code)
+		}
+	}
+}
+
+type fakeAPIKeys map[string][]string
+
+func (f fakeAPIKeys) VerifyAPIKey(_ context.Context, key string) (string, string, []string, error) {
+	scopes, ok := f[key]
+	if !ok {
+		return "", "", nil, errors.New("unknown key")
+	}
+	return "owner", "member", scopes, nil
+}
+
+func TestService_APIKey(t *testing.T) {
+	s, _ := New(Config{Secret: "secret"})
+	s.UseAPIKeys(fakeAPIKeys{"reader": {"films:read"}})
+	handler := s.Authorization(s.RequireScope("films:read")(func(c echo.Context) error {
+		if userID, _ := s.ExtractUserID(c); userID != "owner" {
+			t.Errorf("expected owner, got: %s", userID)
+		}
+		return c.NoContent(http.StatusOK)
+	}))
+	writer := s.Authorization(s.RequireScope("films:write")(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}))
+
+	for _, tt := range []struct {
+		name    string
+		key     string
+		handler echo.HandlerFunc
+		code    int
+	}{
+		{name: "scoped", key: "reader", handler: handler, code: http.StatusOK},
+		{name: "unknown", key: "writer", handler: handler, code: http.StatusUnauthorized},
+		{name: "out of scope", key: "reader", handler: writer, code: http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(APIKeyHeader, tt.key)
+		rec := httptest.NewRecorder()
+		if err := tt.handler(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatalf("%s: handler: %v", tt.name, err)
+		}
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got: %d", tt.name, tt.code, rec.Code)
+		}
+	}
+}